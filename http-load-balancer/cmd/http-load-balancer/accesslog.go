@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusRecorder remembers the status code written through it, and the
+// backend the request went to, so the access log can report them.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	backend *Backend // last backend tried, nil if there was none
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Hijack hands the connection over for a protocol switch, which the proxy
+// answers on the raw connection rather than through WriteHeader.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush streamed responses.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logAccess writes the access log line for r, which peer served, or no
+// backend when peer is nil.
+func (lb *LoadBalancer) logAccess(r *http.Request, status int, peer *Backend, latency time.Duration) {
+	logger := lb.AccessLog
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	backend := ""
+	if peer != nil {
+		backend = peer.URL.String()
+	}
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	logger.Info("Request served",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"backend", backend,
+		"status", status,
+		"latency_us", latency.Microseconds(),
+		"client_ip", clientIP)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHandler keeps every log record it handles.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+// attrs returns the attributes of every recorded line.
+func (h *recordingHandler) attrs() []map[string]slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	lines := make([]map[string]slog.Value, 0, len(h.records))
+	for _, r := range h.records {
+		line := make(map[string]slog.Value)
+		r.Attrs(func(a slog.Attr) bool {
+			line[a.Key] = a.Value
+			return true
+		})
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	}))
+	defer server.Close()
+
+	handler := &recordingHandler{}
+	lb := &LoadBalancer{AccessLog: slog.New(handler)}
+	lb.AddBackend(newTestBackend(t, server.URL))
+
+	req := httptest.NewRequest(http.MethodPost, "/brew?pot=1", nil)
+	req.RemoteAddr = "192.0.2.7:51234"
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+
+	// With no backend in rotation the line has no backend and a 503
+	lb.RemoveBackend(server.URL)
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := handler.attrs()
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+
+	want := map[string]any{
+		"method":    http.MethodPost,
+		"path":      "/brew",
+		"backend":   server.URL,
+		"status":    int64(http.StatusTeapot),
+		"client_ip": "192.0.2.7",
+	}
+	for key, value := range want {
+		if got := lines[0][key].Any(); got != value {
+			t.Errorf("%s = %v, want %v", key, got, value)
+		}
+	}
+	if _, ok := lines[0]["latency_us"]; !ok {
+		t.Errorf("log line has no latency_us")
+	}
+
+	if got := lines[1]["backend"].String(); got != "" {
+		t.Errorf("backend = %q with none available, want empty", got)
+	}
+	if got := lines[1]["status"].Int64(); got != http.StatusServiceUnavailable {
+		t.Errorf("status = %v with none available, want %v", got, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAccessLogAbortedResponse(t *testing.T) {
+	server := abortingServer(t)
+
+	handler := &recordingHandler{}
+	lb := &LoadBalancer{AccessLog: slog.New(handler)}
+	lb.AddBackend(newTestBackend(t, server.URL))
+	lbServer := httptest.NewServer(lb)
+	defer lbServer.Close()
+
+	if resp, err := http.Get(lbServer.URL); err == nil {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	// The line is written as the proxy's handler unwinds, which may be after
+	// the client sees the connection drop
+	deadline := time.Now().Add(5 * time.Second)
+	lines := handler.attrs()
+	for len(lines) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no log line for an aborted response")
+		}
+		time.Sleep(10 * time.Millisecond)
+		lines = handler.attrs()
+	}
+	if got := lines[0]["backend"].String(); got != server.URL {
+		t.Errorf("backend = %q, want %q", got, server.URL)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+type latencyStats struct {
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+type backendStats struct {
+	URL     string       `json:"url"`
+	Alive   bool         `json:"alive"`
+	Latency latencyStats `json:"latency"`
+}
+
+type backendState struct {
+	URL   string `json:"url"`
+	Alive bool   `json:"alive"`
+}
+
+type backendStatus struct {
+	URL      string `json:"url"`
+	Alive    bool   `json:"alive"`
+	Draining bool   `json:"draining"`
+	Weight   int    `json:"weight"`
+	Active   int64  `json:"active"`
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+}
+
+type drainState struct {
+	URL    string `json:"url"`
+	Active int64  `json:"active"`
+}
+
+// AdminHandler returns the handler served on the admin port.
+func (lb *LoadBalancer) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", lb.handleStats)
+	mux.HandleFunc("GET /status", lb.handleStatus)
+	mux.HandleFunc("GET /metrics", lb.handleMetrics)
+	mux.HandleFunc("POST /backends/recheck", lb.handleRecheck)
+	mux.HandleFunc("POST /backends/drain", lb.handleDrain)
+	mux.HandleFunc("POST /backends", lb.handleAddBackend)
+	mux.HandleFunc("DELETE /backends", lb.handleRemoveBackend)
+	return mux
+}
+
+// handleStats reports the health and latency percentiles of every backend.
+func (lb *LoadBalancer) handleStats(w http.ResponseWriter, r *http.Request) {
+	backends := lb.snapshot()
+	stats := make([]backendStats, 0, len(backends))
+	for _, backend := range backends {
+		p := backend.latency.Percentiles(50, 90, 99)
+		stats = append(stats, backendStats{
+			URL:   backend.URL.String(),
+			Alive: backend.IsAlive(),
+			Latency: latencyStats{
+				P50: p[0].Seconds() * 1000,
+				P90: p[1].Seconds() * 1000,
+				P99: p[2].Seconds() * 1000,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Error encoding stats: %v", err)
+	}
+}
+
+// handleStatus reports the health, weight and request counters of every
+// backend.
+func (lb *LoadBalancer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	backends := lb.snapshot()
+	statuses := make([]backendStatus, 0, len(backends))
+	for _, backend := range backends {
+		statuses = append(statuses, backendStatus{
+			URL:      backend.URL.String(),
+			Alive:    backend.IsAlive(),
+			Draining: backend.Draining(),
+			Weight:   backend.Weight,
+			Active:   backend.active.Load(),
+			Requests: backend.requests.Load(),
+			Errors:   backend.errors.Load(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		log.Printf("Error encoding status: %v", err)
+	}
+}
+
+// handleMetrics reports the backend counters in the Prometheus text format.
+func (lb *LoadBalancer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	backends := lb.snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP lb_backend_up Whether the backend is in rotation.")
+	fmt.Fprintln(w, "# TYPE lb_backend_up gauge")
+	for _, backend := range backends {
+		up := 0
+		if backend.IsAlive() {
+			up = 1
+		}
+		fmt.Fprintf(w, "lb_backend_up{backend=%q} %d\n", backend.URL.String(), up)
+	}
+
+	fmt.Fprintln(w, "# HELP lb_backend_requests_total Requests proxied to the backend.")
+	fmt.Fprintln(w, "# TYPE lb_backend_requests_total counter")
+	for _, backend := range backends {
+		fmt.Fprintf(w, "lb_backend_requests_total{backend=%q} %d\n", backend.URL.String(), backend.requests.Load())
+	}
+
+	fmt.Fprintln(w, "# HELP lb_backend_errors_total Requests that failed to reach the backend.")
+	fmt.Fprintln(w, "# TYPE lb_backend_errors_total counter")
+	for _, backend := range backends {
+		fmt.Fprintf(w, "lb_backend_errors_total{backend=%q} %d\n", backend.URL.String(), backend.errors.Load())
+	}
+}
+
+// handleRecheck runs an immediate health check, optionally scoped to the
+// backend given by the url query parameter, and reports the resulting states.
+func (lb *LoadBalancer) handleRecheck(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+
+	checked := lb.checkBackends(target)
+	if target != "" && len(checked) == 0 {
+		http.Error(w, "Unknown backend: "+target, http.StatusNotFound)
+		return
+	}
+
+	states := make([]backendState, 0, len(checked))
+	for _, backend := range checked {
+		states = append(states, backendState{
+			URL:   backend.URL.String(),
+			Alive: backend.IsAlive(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(states); err != nil {
+		log.Printf("Error encoding backend states: %v", err)
+	}
+}
+
+// handleDrain stops sending new requests to the backend given by the url query
+// parameter while letting its in-flight requests finish. The response reports
+// how many are still active; once that reaches zero, as also shown by GET
+// /status, the backend can be removed without cutting anyone off.
+func (lb *LoadBalancer) handleDrain(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	backend := lb.lookup(target)
+	if backend == nil {
+		http.Error(w, "Unknown backend: "+target, http.StatusNotFound)
+		return
+	}
+	backend.draining.Store(true)
+	log.Printf("Draining backend server: %s", target)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(drainState{URL: target, Active: backend.active.Load()}); err != nil {
+		log.Printf("Error encoding drain state: %v", err)
+	}
+}
+
+// handleAddBackend registers the backend described by the JSON body, e.g.
+// {"url": "http://localhost:3002", "weight": 2}, and starts routing to it.
+func (lb *LoadBalancer) handleAddBackend(w http.ResponseWriter, r *http.Request) {
+	var bc backendConfig
+	if err := json.NewDecoder(r.Body).Decode(&bc); err != nil {
+		http.Error(w, "Invalid backend: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	backend, err := bc.build()
+	if err != nil {
+		http.Error(w, "Invalid backend: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !lb.addBackendIfAbsent(backend) {
+		http.Error(w, "Backend already exists: "+backend.URL.String(), http.StatusConflict)
+		return
+	}
+	log.Printf("Added backend server: %s", backend.URL.String())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(backendState{URL: backend.URL.String(), Alive: backend.IsAlive()}); err != nil {
+		log.Printf("Error encoding backend state: %v", err)
+	}
+}
+
+// handleRemoveBackend stops routing to the backend given by the url query
+// parameter.
+func (lb *LoadBalancer) handleRemoveBackend(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if lb.RemoveBackend(target) == nil {
+		http.Error(w, "Unknown backend: "+target, http.StatusNotFound)
+		return
+	}
+	log.Printf("Removed backend server: %s", target)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecheckMarksBackendAlive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	backend := newTestBackend(t, server.URL)
+	backend.SetAlive(false)
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+
+	rec := httptest.NewRecorder()
+	target := "/backends/recheck?url=" + url.QueryEscape(server.URL)
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var states []backendState
+	if err := json.NewDecoder(rec.Body).Decode(&states); err != nil {
+		t.Fatalf("failed to decode states: %v", err)
+	}
+	if len(states) != 1 || !states[0].Alive {
+		t.Errorf("states = %+v, want one alive backend", states)
+	}
+	if !backend.IsAlive() {
+		t.Errorf("backend should be marked alive after recheck")
+	}
+}
+
+func TestRecheckUnknownBackend(t *testing.T) {
+	lb := &LoadBalancer{}
+
+	rec := httptest.NewRecorder()
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends/recheck?url=http://nowhere", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRecheckConcurrentWithScheduledCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, server.URL))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			lb.checkBackends("")
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends/recheck", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+		}
+	}
+	<-done
+}
+
+func TestAdminAddAndRemoveBackend(t *testing.T) {
+	var hits [2]atomic.Int64
+	servers := make([]*httptest.Server, 2)
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+		}))
+		defer servers[i].Close()
+	}
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, servers[0].URL))
+	admin := lb.AdminHandler()
+
+	body := strings.NewReader(`{"url": "` + servers[1].URL + `", "weight": 1}`)
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /backends status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if hits[1].Load() != 2 {
+		t.Errorf("added backend got %d of 4 requests, want 2", hits[1].Load())
+	}
+
+	// Adding the same URL again is a conflict
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends", strings.NewReader(`{"url": "`+servers[1].URL+`"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate POST /backends status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/backends?url="+url.QueryEscape(servers[1].URL), nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /backends status = %v, want %v", rec.Code, http.StatusNoContent)
+	}
+
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if hits[1].Load() != 2 {
+		t.Errorf("removed backend got %d more requests, want none", hits[1].Load()-2)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/backends?url=http://nowhere", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown backend status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminAddInvalidBackend(t *testing.T) {
+	lb := &LoadBalancer{}
+
+	rec := httptest.NewRecorder()
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends", strings.NewReader(`{"url": "not a url"}`)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStatusReportsCounters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	lb := &LoadBalancer{maxRetries: 1}
+	for _, rawURL := range []string{server.URL, downURL} {
+		backend, err := newBackend(rawURL)
+		if err != nil {
+			t.Fatalf("newBackend() error = %v", err)
+		}
+		lb.AddBackend(backend)
+	}
+
+	// One request reaches the dead backend first and is retried on the live one
+	for i := 0; i < 3; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	rec := httptest.NewRecorder()
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var statuses []backendStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("len(statuses) = %v, want 2", len(statuses))
+	}
+
+	want := []backendStatus{
+		{URL: server.URL, Alive: true, Weight: 1, Requests: 3, Errors: 0},
+		{URL: downURL, Alive: false, Weight: 1, Requests: 1, Errors: 1},
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("statuses[%d] = %+v, want %+v", i, statuses[i], want[i])
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`lb_backend_requests_total{backend="` + server.URL + `"} 3`,
+		`lb_backend_errors_total{backend="` + downURL + `"} 1`,
+		`lb_backend_up{backend="` + downURL + `"} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, rec.Body)
+		}
+	}
+}
+
+func TestAdminDrainBackend(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var slowHits atomic.Int64
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slowHits.Add(1) == 1 {
+			close(started)
+			<-unblock
+		}
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fast")
+	}))
+	defer fast.Close()
+
+	lb := &LoadBalancer{strategy: &WeightedRoundRobin{}}
+	slowBackend := newTestBackend(t, slow.URL)
+	lb.AddBackend(slowBackend)
+	lb.AddBackend(newTestBackend(t, fast.URL))
+	admin := lb.AdminHandler()
+
+	// The first pick goes to the slow backend and stays in flight
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(inFlight, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends/drain?url="+url.QueryEscape(slow.URL), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /backends/drain status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var state drainState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode drain state: %v", err)
+	}
+	if state != (drainState{URL: slow.URL, Active: 1}) {
+		t.Errorf("drain state = %+v, want %s with 1 active", state, slow.URL)
+	}
+
+	for i := 0; i < 4; i++ {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+			t.Errorf("request %d: got %v %q, want 200 %q", i, rec.Code, rec.Body.String(), "fast")
+		}
+	}
+	if n := slowHits.Load(); n != 1 {
+		t.Errorf("draining backend got %d new requests, want none", n-1)
+	}
+
+	close(unblock)
+	<-done
+	if inFlight.Code != http.StatusOK || inFlight.Body.String() != "slow" {
+		t.Errorf("in-flight request: got %v %q, want 200 %q", inFlight.Code, inFlight.Body.String(), "slow")
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var statuses []backendStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if len(statuses) != 2 || !statuses[0].Draining || statuses[0].Active != 0 {
+		t.Errorf("statuses = %+v, want the first backend draining with none active", statuses)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends/drain?url=http://nowhere", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("drain unknown backend status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminDrainAfterAbortedResponse(t *testing.T) {
+	server := abortingServer(t)
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, server.URL))
+	lbServer := httptest.NewServer(lb)
+	defer lbServer.Close()
+	admin := lb.AdminHandler()
+
+	if resp, err := http.Get(lbServer.URL); err == nil {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backends/drain?url="+url.QueryEscape(server.URL), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /backends/drain status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	// The aborted request must not stay counted as in flight
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		var statuses []backendStatus
+		if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		if len(statuses) != 1 || !statuses[0].Draining {
+			t.Fatalf("statuses = %+v, want one draining backend", statuses)
+		}
+		if statuses[0].Active == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("drained backend still has %d active after its response was aborted", statuses[0].Active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
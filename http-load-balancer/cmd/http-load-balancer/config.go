@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// backendConfig describes one backend in the config file
+type backendConfig struct {
+	URL      string   `json:"url"`
+	Weight   *int     `json:"weight,omitempty"` // defaults to 1
+	Tags     []string `json:"tags,omitempty"`
+	MaxConns int      `json:"max_conns,omitempty"` // 0 for no limit
+}
+
+// config is the JSON config file read with -config
+type config struct {
+	Backends []backendConfig `json:"backends"`
+}
+
+// loadConfig reads the backends from the JSON config file at path, e.g.
+//
+//	{"backends": [{"url": "http://localhost:3001", "weight": 3}]}
+func loadConfig(path string) ([]*Backend, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	backends := make([]*Backend, 0, len(cfg.Backends))
+	for i, bc := range cfg.Backends {
+		backend, err := bc.build()
+		if err != nil {
+			return nil, fmt.Errorf("backend %d (%q): %w", i, bc.URL, err)
+		}
+		backends = append(backends, backend)
+	}
+	return backends, nil
+}
+
+// build creates the backend described by bc
+func (bc backendConfig) build() (*Backend, error) {
+	backend, err := newBackend(bc.URL)
+	if err != nil {
+		return nil, err
+	}
+	if bc.Weight != nil {
+		if *bc.Weight < 0 {
+			return nil, fmt.Errorf("invalid weight %d", *bc.Weight)
+		}
+		backend.Weight = *bc.Weight
+	}
+	backend.Tags = append(backend.Tags, bc.Tags...)
+	if bc.MaxConns < 0 {
+		return nil, fmt.Errorf("invalid max_conns %d", bc.MaxConns)
+	}
+	if bc.MaxConns > 0 {
+		backend.MaxConns = bc.MaxConns
+	}
+	return backend, nil
+}
+
+// parseBackendList builds backends from a comma-separated list of URLs as
+// accepted by newBackend, e.g. "http://a:3001?weight=3,http://b:3001".
+func parseBackendList(list string) ([]*Backend, error) {
+	var backends []*Backend
+	for _, rawURL := range strings.Split(list, ",") {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			continue
+		}
+		backend, err := newBackend(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", rawURL, err)
+		}
+		backends = append(backends, backend)
+	}
+	return backends, nil
+}
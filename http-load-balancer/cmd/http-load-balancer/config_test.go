@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	contents := `{
+		"backends": [
+			{"url": "http://localhost:3001", "weight": 3, "tags": ["canary"], "max_conns": 10},
+			{"url": "http://localhost:3002"},
+			{"url": "http://localhost:3003", "weight": 0}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	backends, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if len(backends) != 3 {
+		t.Fatalf("len(backends) = %v, want 3", len(backends))
+	}
+
+	want := []struct {
+		url    string
+		weight int
+	}{
+		{"http://localhost:3001", 3},
+		{"http://localhost:3002", 1},
+		{"http://localhost:3003", 0},
+	}
+	for i, w := range want {
+		if got := backends[i].URL.String(); got != w.url {
+			t.Errorf("backends[%d].URL = %v, want %v", i, got, w.url)
+		}
+		if backends[i].Weight != w.weight {
+			t.Errorf("backends[%d].Weight = %v, want %v", i, backends[i].Weight, w.weight)
+		}
+		if !backends[i].IsAlive() || backends[i].ReverseProxy == nil {
+			t.Errorf("backends[%d] is not ready to serve", i)
+		}
+	}
+	if len(backends[0].Tags) != 1 || backends[0].Tags[0] != "canary" {
+		t.Errorf("backends[0].Tags = %v, want [canary]", backends[0].Tags)
+	}
+	if backends[0].MaxConns != 10 || backends[1].MaxConns != 0 {
+		t.Errorf("MaxConns = %d, %d, want 10, 0", backends[0].MaxConns, backends[1].MaxConns)
+	}
+}
+
+func TestLoadConfigInvalidURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"backends": [{"url": "localhost:3001"}]}`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if _, err := loadConfig(path); err == nil {
+		t.Errorf("loadConfig() expected error for a URL without scheme")
+	}
+}
+
+func TestParseBackendList(t *testing.T) {
+	backends, err := parseBackendList("http://localhost:3001?weight=2, http://localhost:3002")
+	if err != nil {
+		t.Fatalf("parseBackendList() error = %v", err)
+	}
+	if len(backends) != 2 {
+		t.Fatalf("len(backends) = %v, want 2", len(backends))
+	}
+	if backends[0].Weight != 2 || backends[1].Weight != 1 {
+		t.Errorf("weights = %d, %d, want 2, 1", backends[0].Weight, backends[1].Weight)
+	}
+
+	if _, err := parseBackendList("http://localhost:3001,:bad"); err == nil {
+		t.Errorf("parseBackendList() expected error for an invalid URL")
+	}
+}
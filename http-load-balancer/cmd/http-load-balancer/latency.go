@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencySampleSize bounds how many recent samples each backend keeps.
+const latencySampleSize = 1024
+
+// latencySketch records the most recent response times of a backend in a
+// fixed-size ring buffer so percentiles can be computed with bounded memory.
+type latencySketch struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// Observe records a single response time.
+func (s *latencySketch) Observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.samples) < latencySampleSize {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % latencySampleSize
+}
+
+// Percentiles returns the requested percentiles (0-100) over the recorded
+// samples using the nearest-rank method. It returns zeros when empty.
+func (s *latencySketch) Percentiles(ps ...float64) []time.Duration {
+	s.mu.Lock()
+	sorted := append([]time.Duration(nil), s.samples...)
+	s.mu.Unlock()
+
+	result := make([]time.Duration, len(ps))
+	if len(sorted) == 0 {
+		return result
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, p := range ps {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		result[i] = sorted[rank-1]
+	}
+	return result
+}
+
+// ewmaWeight is how much a new sample moves the moving average.
+const ewmaWeight = 0.3
+
+// ewma is an exponentially-weighted moving average of response times. Recent
+// samples dominate, so it tracks a backend that speeds up or slows down.
+type ewma struct {
+	bits atomic.Uint64 // float64 nanoseconds, 0 until the first sample
+}
+
+// Observe folds a single response time into the average.
+func (e *ewma) Observe(d time.Duration) {
+	sample := math.Max(float64(d), 1)
+	for {
+		old := e.bits.Load()
+		next := sample
+		if old != 0 {
+			next = ewmaWeight*sample + (1-ewmaWeight)*math.Float64frombits(old)
+		}
+		if e.bits.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// Value returns the current average, and false when nothing was observed.
+func (e *ewma) Value() (time.Duration, bool) {
+	bits := e.bits.Load()
+	if bits == 0 {
+		return 0, false
+	}
+	return time.Duration(math.Float64frombits(bits)), true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLatencySketchPercentiles(t *testing.T) {
+	var sketch latencySketch
+	for i := 1; i <= 100; i++ {
+		sketch.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	p := sketch.Percentiles(50, 90, 99)
+	want := []time.Duration{50 * time.Millisecond, 90 * time.Millisecond, 99 * time.Millisecond}
+	for i := range want {
+		if p[i] != want[i] {
+			t.Errorf("Percentiles()[%d] = %v, want %v", i, p[i], want[i])
+		}
+	}
+}
+
+func TestLatencySketchIsBounded(t *testing.T) {
+	var sketch latencySketch
+	for i := 0; i < latencySampleSize; i++ {
+		sketch.Observe(time.Second)
+	}
+	// Newer samples push out the oldest ones
+	for i := 0; i < latencySampleSize; i++ {
+		sketch.Observe(time.Millisecond)
+	}
+
+	if len(sketch.samples) != latencySampleSize {
+		t.Errorf("len(samples) = %v, want %v", len(sketch.samples), latencySampleSize)
+	}
+	if p := sketch.Percentiles(99)[0]; p != time.Millisecond {
+		t.Errorf("p99 = %v, want %v", p, time.Millisecond)
+	}
+}
+
+func TestStatsReportsPercentiles(t *testing.T) {
+	backendURL, _ := url.Parse("http://localhost:3001")
+	backend := &Backend{URL: backendURL, Alive: true}
+	for i := 1; i <= 100; i++ {
+		backend.latency.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+
+	rec := httptest.NewRecorder()
+	lb.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var stats []backendStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("len(stats) = %v, want 1", len(stats))
+	}
+
+	latency := stats[0].Latency
+	if latency.P50 < 45 || latency.P50 > 55 {
+		t.Errorf("p50 = %vms, want ~50ms", latency.P50)
+	}
+	if latency.P90 < 85 || latency.P90 > 95 {
+		t.Errorf("p90 = %vms, want ~90ms", latency.P90)
+	}
+	if latency.P99 < 95 || latency.P99 > 100 {
+		t.Errorf("p99 = %vms, want ~99ms", latency.P99)
+	}
+}
+
+func TestServeHTTPRecordsLatency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, server.URL))
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+		}
+	}
+
+	if n := len(lb.backends[0].latency.samples); n != 5 {
+		t.Errorf("recorded samples = %v, want 5", n)
+	}
+}
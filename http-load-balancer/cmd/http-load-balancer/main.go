@@ -1,20 +1,47 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 type Backend struct {
 	URL          *url.URL
 	Alive        bool
+	Weight       int
+	Tags         []string
+	MaxConns     int // concurrent request cap, 0 for no limit
 	mu           sync.RWMutex
 	ReverseProxy *httputil.ReverseProxy
+	latency      latencySketch
+	responseTime ewma
+	requests     atomic.Uint64 // proxy attempts sent to the backend
+	errors       atomic.Uint64 // proxy attempts that failed to reach it
+	active       atomic.Int64  // requests currently being proxied
+	draining     atomic.Bool   // set by the admin API to stop new requests
+	markedDown   atomic.Bool   // set by a proxy error, cleared by the next probe
+
+	// Consecutive probe results, guarded by the load balancer's checkMu
+	failures  int
+	successes int
 }
 
 func (b *Backend) IsAlive() bool {
@@ -29,114 +56,637 @@ func (b *Backend) SetAlive(alive bool) {
 	b.Alive = alive
 }
 
+// markDown takes the backend out of rotation after a proxy error. Its probe
+// counters are left to the next recordProbe, the only place that may touch
+// them.
+func (b *Backend) markDown() {
+	b.SetAlive(false)
+	b.markedDown.Store(true)
+}
+
+// Saturated reports whether the backend is already serving MaxConns requests
+func (b *Backend) Saturated() bool {
+	return b.MaxConns > 0 && b.active.Load() >= int64(b.MaxConns)
+}
+
+// Draining reports whether the backend has been taken out of rotation for new
+// requests while its in-flight ones finish
+func (b *Backend) Draining() bool {
+	return b.draining.Load()
+}
+
+// available reports whether the backend can take another request
+func (b *Backend) available() bool {
+	return b.IsAlive() && !b.Draining() && !b.Saturated()
+}
+
+// acquire reserves one of the backend's MaxConns slots, reporting false when
+// all of them are taken. Each successful acquire must be paired with release.
+func (b *Backend) acquire() bool {
+	for {
+		n := b.active.Load()
+		if b.MaxConns > 0 && n >= int64(b.MaxConns) {
+			return false
+		}
+		if b.active.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// release frees a slot reserved by acquire
+func (b *Backend) release() {
+	b.active.Add(-1)
+}
+
+// Health check defaults used when the LoadBalancer fields are left zero
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckPath     = "/"
+	defaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// maxReplayBody is the largest request body held in memory so that it can be
+// replayed against another backend
+const maxReplayBody = 1 << 20
+
+// shutdownTimeout bounds how long serve waits for in-flight requests to drain
+const shutdownTimeout = 30 * time.Second
+
+// healthClient is shared by all probes so connections to backends are reused
+// between checks. Each probe bounds itself with HealthCheckTimeout.
+var healthClient = &http.Client{
+	Transport: http.DefaultTransport.(*http.Transport).Clone(),
+}
+
 type LoadBalancer struct {
-	backends []*Backend
-	current  uint64
+	mu         sync.RWMutex // guards backends, which the admin API changes at runtime
+	backends   []*Backend
+	current    uint64
+	strategy   Strategy   // picks backends; nil means plain round-robin
+	maxRetries int        // further backends tried after a failed proxy attempt
+	checkMu    sync.Mutex // serializes scheduled and on-demand health checks
+
+	HealthCheckInterval time.Duration // time between active health checks
+	HealthCheckPath     string        // path probed on each backend, expecting 200
+	HealthCheckTimeout  time.Duration // time a single probe may take
+	UnhealthyThreshold  int           // consecutive failed probes before a backend is marked down
+	HealthyThreshold    int           // consecutive passed probes before a backend is marked up
+	RequestTimeout      time.Duration // time a proxied request may take before 504, 0 for no limit
+	AccessLog           *slog.Logger  // receives a line per request, nil for slog.Default()
+}
+
+// attemptKey is the context key under which ServeHTTP passes a *proxyAttempt
+// to the backend's proxy error handler.
+type attemptKey struct{}
+
+// proxyAttempt records why a single proxy attempt failed, if it did.
+type proxyAttempt struct {
+	err error
 }
 
 func (lb *LoadBalancer) AddBackend(backend *Backend) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.backends = append(lb.backends, backend)
+}
+
+// lookup returns the backend with the given URL, or nil if there is none.
+func (lb *LoadBalancer) lookup(rawURL string) *Backend {
+	for _, backend := range lb.snapshot() {
+		if backend.URL.String() == rawURL {
+			return backend
+		}
+	}
+	return nil
+}
+
+// addBackendIfAbsent adds backend unless one with the same URL is already
+// registered, and reports whether it was added.
+func (lb *LoadBalancer) addBackendIfAbsent(backend *Backend) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for _, existing := range lb.backends {
+		if existing.URL.String() == backend.URL.String() {
+			return false
+		}
+	}
 	lb.backends = append(lb.backends, backend)
+	return true
+}
+
+// RemoveBackend removes the backend with the given URL and returns it, or nil
+// if there is none.
+func (lb *LoadBalancer) RemoveBackend(rawURL string) *Backend {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	for i, backend := range lb.backends {
+		if backend.URL.String() == rawURL {
+			lb.backends = append(lb.backends[:i:i], lb.backends[i+1:]...)
+			return backend
+		}
+	}
+	return nil
 }
 
-// NextIndex returns the index of the next backend server in a round-robin fashion.
-func (lb *LoadBalancer) NextIndex() int {
-	return int(atomic.AddUint64(&lb.current, 1) % uint64(len(lb.backends)))
+// snapshot returns the current backends. The slice must not be modified.
+func (lb *LoadBalancer) snapshot() []*Backend {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.backends
+}
+
+// NextIndex returns the index of the next of n backend servers in a
+// round-robin fashion.
+func (lb *LoadBalancer) NextIndex(n int) int {
+	return int(atomic.AddUint64(&lb.current, 1) % uint64(n))
 }
 
 func (lb *LoadBalancer) GetNextPeer() *Backend {
-	next := lb.NextIndex()
-	l := len(lb.backends) + next
+	backends := lb.snapshot()
+	if len(backends) == 0 {
+		return nil
+	}
+
+	next := lb.NextIndex(len(backends))
+	l := len(backends) + next
 
 	for i := next; i < l; i++ {
-		idx := i % len(lb.backends)
-		if lb.backends[idx].IsAlive() {
+		idx := i % len(backends)
+		if backends[idx].available() {
 			if i != next {
 				atomic.StoreUint64(&lb.current, uint64(idx))
 			}
-			return lb.backends[idx]
+			return backends[idx]
 		}
 	}
 	return nil
 }
 
+// nextPeer picks the backend for r using the configured strategy
+func (lb *LoadBalancer) nextPeer(r *http.Request) *Backend {
+	if lb.strategy == nil {
+		return lb.GetNextPeer()
+	}
+	return lb.strategy.Next(lb.snapshot(), r)
+}
+
+// ServeHTTP proxies r to a backend and writes an access log line once the
+// response is done.
 func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	peer := lb.GetNextPeer()
-	if peer == nil {
-		peer.ReverseProxy.ServeHTTP(w, r)
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	defer func() {
+		// A response aborted with http.ErrAbortHandler is logged too, then the
+		// panic goes on to the server so it drops the connection
+		err := recover()
+		lb.logAccess(r, rec.status, rec.backend, time.Since(start))
+		if err != nil {
+			panic(err)
+		}
+	}()
+	lb.proxy(rec, r)
+}
+
+// proxy sends r to a backend, noting the last one tried in w. When the
+// backend cannot be reached the request is replayed against the next alive
+// backend, up to maxRetries times. A request that is not idempotent is only
+// replayed when the failed backend never accepted the connection, since it
+// may otherwise have acted on it. The body is buffered up front when a retry
+// is possible and it fits in maxReplayBody; a larger body is streamed to a
+// single backend. Backends at their MaxConns limit are skipped, and when every
+// backend is down or saturated the client gets 503.
+// A request that outlives RequestTimeout is answered with 504 and not retried.
+// Upgrade requests such as WebSockets are handed to the backend's
+// ReverseProxy, which switches protocols if the backend agrees and otherwise
+// relays the backend's refusal.
+func (lb *LoadBalancer) proxy(w *statusRecorder, r *http.Request) {
+	upgrade := isUpgrade(r)
+	retries := lb.maxRetries
+	if upgrade {
+		retries = 0
+	}
+
+	var body []byte
+	var stream io.ReadCloser
+	if retries > 0 && r.Body != nil && r.Body != http.NoBody {
+		buffered, err := io.ReadAll(io.LimitReader(r.Body, maxReplayBody+1))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(buffered) > maxReplayBody {
+			// Too large to hold for a replay: send what was read followed by
+			// the rest of the body, once
+			stream = readCloser{io.MultiReader(bytes.NewReader(buffered), r.Body), r.Body}
+			retries = 0
+		} else {
+			body = buffered
+		}
+	}
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		// Once the client is gone there is nobody left to retry for
+		if r.Context().Err() != nil {
+			break
+		}
+		peer := lb.nextPeer(r)
+		if peer == nil {
+			break
+		}
+		// Another request may have taken the last slot since peer was picked
+		if !peer.acquire() {
+			continue
+		}
+		w.backend = peer
+
+		try := &proxyAttempt{}
+		start := time.Now()
+		func() {
+			ctx, cancel := lb.requestContext(r.Context(), upgrade)
+			defer cancel()
+			// ReverseProxy panics with http.ErrAbortHandler when a response
+			// breaks off mid-body, which must not leak the slot
+			defer peer.release()
+
+			req := r.WithContext(context.WithValue(ctx, attemptKey{}, try))
+			if body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			} else if stream != nil {
+				req.Body = stream
+			}
+			peer.requests.Add(1)
+			peer.ReverseProxy.ServeHTTP(w, req)
+		}()
+		if try.err == nil {
+			// An upgraded connection's lifetime says nothing about how fast
+			// the backend responds
+			if upgrade {
+				return
+			}
+			elapsed := time.Since(start)
+			peer.latency.Observe(elapsed)
+			peer.responseTime.Observe(elapsed)
+			return
+		}
+
+		peer.errors.Add(1)
+		if errors.Is(try.err, context.DeadlineExceeded) {
+			log.Printf("Request to %s timed out after %v", peer.URL.String(), time.Since(start))
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		}
+		log.Printf("Attempt %d to %s failed: %v", attempt+1, peer.URL.String(), try.err)
+		if !replayable(r, try.err) {
+			break
+		}
+	}
+
+	if w.backend != nil {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		return
 	}
 	http.Error(w, "No available backend servers", http.StatusServiceUnavailable)
 }
 
-func isBackendAlive(url *url.URL) bool {
-	conn, err := http.Get(url.String())
+// requestContext bounds a proxy attempt by RequestTimeout, if one is set.
+// Upgraded connections stay open for as long as the client keeps them, and
+// cancelling the context would close them, so upgrades are never bounded.
+func (lb *LoadBalancer) requestContext(ctx context.Context, upgrade bool) (context.Context, context.CancelFunc) {
+	if lb.RequestTimeout <= 0 || upgrade {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, lb.RequestTimeout)
+}
+
+// replayable reports whether a request whose attempt failed with err may be
+// sent to another backend. Idempotent requests always may. Any other request
+// may only when the connection was never made, so the backend cannot have
+// received any of it.
+func replayable(r *http.Request, err error) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// readCloser reads from one source and closes another, for a body that is
+// partly buffered
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isUpgrade reports whether r asks to switch protocols, as a WebSocket
+// handshake does
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isBackendAlive probes the health check path of the backend at u and reports
+// whether it answered 200 within the health check timeout.
+func (lb *LoadBalancer) isBackendAlive(u *url.URL) bool {
+	path := lb.HealthCheckPath
+	if path == "" {
+		path = defaultHealthCheckPath
+	}
+	timeout := lb.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.JoinPath(path).String(), nil)
 	if err != nil {
 		return false
 	}
-	defer conn.Body.Close()
-	return conn.StatusCode == 200
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
 }
 
-// healthCheck performs periodic health checks on all backends
-func healthCheck(lb *LoadBalancer) {
-	t := time.NewTicker(time.Second * 10)
+// healthCheck performs periodic health checks on all backends until ctx is
+// cancelled
+func healthCheck(ctx context.Context, lb *LoadBalancer) {
+	interval := lb.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	t := time.NewTicker(interval)
 	defer t.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-t.C:
 			log.Println("Starting health check...")
-			for _, backend := range lb.backends {
-				alive := isBackendAlive(backend.URL)
-				backend.SetAlive(alive)
-				status := "UP"
-				if !alive {
-					status = "DOWN"
-				}
-				log.Printf("Backend %s is %s", backend.URL.String(), status)
-			}
+			lb.checkBackends("")
 		}
 	}
 }
 
-func main() {
-	serverList := []string{
-		"http://localhost:3001",
+// checkBackends probes the backend matching target, or every backend when
+// target is empty, and updates its alive state. Backends are probed
+// concurrently so a slow one doesn't delay the rest. It returns the probed
+// backends.
+func (lb *LoadBalancer) checkBackends(target string) []*Backend {
+	lb.checkMu.Lock()
+	defer lb.checkMu.Unlock()
+
+	var checked []*Backend
+	for _, backend := range lb.snapshot() {
+		if target != "" && backend.URL.String() != target {
+			continue
+		}
+		checked = append(checked, backend)
 	}
 
-	lb := &LoadBalancer{}
+	results := make([]bool, len(checked))
+	var wg sync.WaitGroup
+	for i, backend := range checked {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = lb.isBackendAlive(backend.URL)
+		}()
+	}
+	wg.Wait()
 
-	for _, server := range serverList {
-		serverURL, err := url.Parse(server)
-		if err != nil {
-			log.Fatalf("Failed to parse server URL %s: %v", server, err)
+	for i, backend := range checked {
+		lb.recordProbe(backend, results[i])
+		status := "UP"
+		if !backend.IsAlive() {
+			status = "DOWN"
+		}
+		log.Printf("Backend %s is %s", backend.URL.String(), status)
+	}
+	return checked
+}
+
+// recordProbe counts a probe result and flips the backend's alive state once
+// UnhealthyThreshold failures or HealthyThreshold successes occur in a row, so
+// a single blip doesn't cause flapping. Zero thresholds mean 1. The caller
+// must hold lb.checkMu.
+func (lb *LoadBalancer) recordProbe(backend *Backend, passed bool) {
+	// Probes that passed before a proxy error took the backend down must not
+	// count towards bringing it back
+	if backend.markedDown.Swap(false) {
+		backend.successes = 0
+		backend.failures = 0
+	}
+
+	if passed {
+		backend.successes++
+		backend.failures = 0
+		if backend.successes >= max(lb.HealthyThreshold, 1) {
+			backend.SetAlive(true)
+		}
+		return
+	}
+
+	backend.failures++
+	backend.successes = 0
+	if backend.failures >= max(lb.UnhealthyThreshold, 1) {
+		backend.SetAlive(false)
+	}
+}
+
+// newBackend builds a backend from a URL such as
+// http://host:3001?weight=3&tag=canary&max_conns=100. The weight, tag and
+// max_conns query parameters configure the backend and are stripped from the
+// proxy target.
+func newBackend(rawURL string) (*Backend, error) {
+	serverURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server URL: %w", err)
+	}
+	if (serverURL.Scheme != "http" && serverURL.Scheme != "https") || serverURL.Host == "" {
+		return nil, fmt.Errorf("server URL %q must be an absolute http or https URL", rawURL)
+	}
+
+	query := serverURL.Query()
+
+	weight := 1
+	if w := query.Get("weight"); w != "" {
+		weight, err = strconv.Atoi(w)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q", w)
 		}
+	}
+	tags := query["tag"]
+
+	maxConns := 0
+	if m := query.Get("max_conns"); m != "" {
+		maxConns, err = strconv.Atoi(m)
+		if err != nil || maxConns < 0 {
+			return nil, fmt.Errorf("invalid max_conns %q", m)
+		}
+	}
+
+	query.Del("weight")
+	query.Del("tag")
+	query.Del("max_conns")
+	serverURL.RawQuery = query.Encode()
 
-		proxy := httputil.NewSingleHostReverseProxy(serverURL)
+	backend := &Backend{
+		URL:          serverURL,
+		Alive:        true,
+		Weight:       weight,
+		Tags:         tags,
+		MaxConns:     maxConns,
+		ReverseProxy: httputil.NewSingleHostReverseProxy(serverURL),
+	}
 
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("Error proxying request to %s: %v", serverURL.String(), err)
+	// Tell the backend which host and scheme the client used. ReverseProxy
+	// itself appends the client IP to any X-Forwarded-For chain.
+	director := backend.ReverseProxy.Director
+	backend.ReverseProxy.Director = func(req *http.Request) {
+		director(req)
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
 		}
+		req.Header.Set("X-Forwarded-Host", req.Host)
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
 
-		backend := &Backend{
-			URL:          serverURL,
-			Alive:        true,
-			ReverseProxy: proxy,
+	// Take a failing backend out of rotation right away instead of waiting for
+	// the next health check, which brings it back once it answers again. Inside
+	// ServeHTTP nothing is written so the request can go to another backend.
+	backend.ReverseProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// A timed out request says the backend is slow, not that it is down
+		if errors.Is(err, context.DeadlineExceeded) {
+			if attempt, ok := r.Context().Value(attemptKey{}).(*proxyAttempt); ok {
+				attempt.err = err
+				return
+			}
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		}
+
+		// Nor does one the client hung up on, whose transport error is only a
+		// consequence of the cancellation
+		if errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+			if attempt, ok := r.Context().Value(attemptKey{}).(*proxyAttempt); ok {
+				attempt.err = err
+				return
+			}
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
 		}
 
+		log.Printf("Error proxying request to %s, marking it DOWN: %v", serverURL.String(), err)
+		backend.markDown()
+		if attempt, ok := r.Context().Value(attemptKey{}).(*proxyAttempt); ok {
+			attempt.err = err
+			return
+		}
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	}
+
+	return backend, nil
+}
+
+// serve runs the load balancer on ln and the admin API on adminLn until ctx
+// is cancelled, then waits up to shutdownTimeout for in-flight requests to
+// finish. The health checker runs for as long as serve does.
+func serve(ctx context.Context, lb *LoadBalancer, ln, adminLn net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go healthCheck(ctx, lb)
+
+	server := &http.Server{Handler: lb}
+	adminServer := &http.Server{Handler: lb.AdminHandler()}
+
+	errs := make(chan error, 2)
+	go func() { errs <- server.Serve(ln) }()
+	go func() { errs <- adminServer.Serve(adminLn) }()
+
+	select {
+	case err := <-errs:
+		server.Close()
+		adminServer.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down load balancer...")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	return errors.Join(server.Shutdown(shutdownCtx), adminServer.Shutdown(shutdownCtx))
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to a JSON config file listing the backends")
+	backendList := flag.String("backends", "http://localhost:3001", "comma-separated backend URLs, used when -config is not set")
+	requestTimeout := flag.Duration("request-timeout", 0, "time a proxied request may take before the client gets 504 (0 for no limit)")
+	adminAddr := flag.String("admin-addr", "127.0.0.1:8081", "address of the unauthenticated admin API; keep it off public interfaces")
+	flag.Parse()
+
+	var backends []*Backend
+	var err error
+	if *configPath != "" {
+		backends, err = loadConfig(*configPath)
+	} else {
+		backends, err = parseBackendList(*backendList)
+	}
+	if err != nil {
+		log.Fatalf("Failed to set up backends: %v", err)
+	}
+
+	lb := &LoadBalancer{
+		strategy:   &WeightedRoundRobin{},
+		maxRetries: 2,
+
+		RequestTimeout: *requestTimeout,
+		AccessLog:      slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+
+	for _, backend := range backends {
 		lb.AddBackend(backend)
 		log.Printf("Added backend server: %s", backend.URL.String())
 	}
 
-	go healthCheck(lb)
-
-	server := http.Server{
-		Addr:    ":8080",
-		Handler: lb,
+	ln, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	adminLn, err := net.Listen("tcp", *adminAddr)
+	if err != nil {
+		log.Fatalf("Failed to start admin server: %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Starting admin server on %s", adminLn.Addr())
 	log.Println("Starting load balancer on :8080")
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := serve(ctx, lb, ln, adminLn); err != nil {
+		log.Fatalf("Server error: %v", err)
 	}
+	log.Println("Load balancer stopped")
 }
@@ -0,0 +1,850 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestBackend builds an alive backend proxying to rawURL.
+func newTestBackend(t *testing.T, rawURL string) *Backend {
+	t.Helper()
+
+	serverURL, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse server URL %s: %v", rawURL, err)
+	}
+
+	return &Backend{
+		URL:          serverURL,
+		Alive:        true,
+		Weight:       1,
+		ReverseProxy: httputil.NewSingleHostReverseProxy(serverURL),
+	}
+}
+
+func TestNewBackendParsesMetadata(t *testing.T) {
+	backend, err := newBackend("http://localhost:3001?weight=3&tag=canary&tag=eu&max_conns=5")
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+
+	if backend.Weight != 3 {
+		t.Errorf("Weight = %v, want %v", backend.Weight, 3)
+	}
+	if backend.MaxConns != 5 {
+		t.Errorf("MaxConns = %v, want %v", backend.MaxConns, 5)
+	}
+	if len(backend.Tags) != 2 || backend.Tags[0] != "canary" || backend.Tags[1] != "eu" {
+		t.Errorf("Tags = %v, want [canary eu]", backend.Tags)
+	}
+	if got := backend.URL.String(); got != "http://localhost:3001" {
+		t.Errorf("URL = %v, want %v", got, "http://localhost:3001")
+	}
+
+	// The proxy target must not carry the metadata parameters
+	req := httptest.NewRequest(http.MethodGet, "http://lb/path?q=1", nil)
+	backend.ReverseProxy.Director(req)
+	if req.URL.Host != "localhost:3001" {
+		t.Errorf("proxied Host = %v, want %v", req.URL.Host, "localhost:3001")
+	}
+	if req.URL.RawQuery != "q=1" {
+		t.Errorf("proxied RawQuery = %q, want %q", req.URL.RawQuery, "q=1")
+	}
+}
+
+func TestNewBackendDefaults(t *testing.T) {
+	backend, err := newBackend("http://localhost:3002")
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	if backend.Weight != 1 {
+		t.Errorf("Weight = %v, want %v", backend.Weight, 1)
+	}
+	if len(backend.Tags) != 0 {
+		t.Errorf("Tags = %v, want none", backend.Tags)
+	}
+}
+
+func TestNewBackendInvalidWeight(t *testing.T) {
+	if _, err := newBackend("http://localhost:3001?weight=abc"); err == nil {
+		t.Errorf("newBackend() expected error for invalid weight")
+	}
+}
+
+func TestProxyErrorMarksBackendDown(t *testing.T) {
+	// A backend that is no longer listening
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	backend, err := newBackend(downURL)
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusBadGateway)
+	}
+	if backend.IsAlive() {
+		t.Errorf("backend still alive after a proxy error, want it marked down")
+	}
+
+	// With the only backend down, later requests are rejected up front
+	rec = httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestClientCancelKeepsBackendAlive(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	backend, err := newBackend(server.URL)
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}()
+	<-started
+	cancel()
+	<-done
+
+	if !backend.IsAlive() {
+		t.Errorf("backend marked down after the client hung up")
+	}
+}
+
+func TestClientCancelStopsRetries(t *testing.T) {
+	started := make(chan struct{}, 3)
+	lb := &LoadBalancer{maxRetries: 2}
+	var backends []*Backend
+	for range 3 {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		backend, err := newBackend(server.URL)
+		if err != nil {
+			t.Fatalf("newBackend() error = %v", err)
+		}
+		lb.AddBackend(backend)
+		backends = append(backends, backend)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}()
+	<-started
+	cancel()
+	<-done
+
+	var attempts uint64
+	for _, backend := range backends {
+		attempts += backend.requests.Load()
+	}
+	if attempts != 1 {
+		t.Errorf("canceled request was tried %d times, want 1", attempts)
+	}
+	for _, backend := range backends {
+		if !backend.IsAlive() {
+			t.Errorf("backend %s marked down after the client hung up", backend.URL)
+		}
+	}
+}
+
+func TestServeHTTPRetriesNextBackend(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer healthy.Close()
+
+	lb := &LoadBalancer{maxRetries: 1}
+	for _, rawURL := range []string{downURL, healthy.URL} {
+		backend, err := newBackend(rawURL)
+		if err != nil {
+			t.Fatalf("newBackend() error = %v", err)
+		}
+		lb.AddBackend(backend)
+	}
+	// Start the round-robin on the failing backend
+	lb.current = uint64(len(lb.backends) - 1)
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	// The replayed request must carry the original body
+	if rec.Body.String() != "payload" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "payload")
+	}
+	if lb.backends[0].IsAlive() {
+		t.Errorf("failing backend still alive, want it marked down")
+	}
+}
+
+func TestServeHTTPRetriesExhausted(t *testing.T) {
+	lb := &LoadBalancer{maxRetries: 2}
+	for i := 0; i < 2; i++ {
+		down := httptest.NewServer(http.NotFoundHandler())
+		backend, err := newBackend(down.URL)
+		down.Close()
+		if err != nil {
+			t.Fatalf("newBackend() error = %v", err)
+		}
+		lb.AddBackend(backend)
+	}
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusBadGateway)
+	}
+}
+
+func TestServeHTTPDoesNotReplayNonIdempotentRequest(t *testing.T) {
+	// Reads the request, then hangs up without answering
+	hangup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer hangup.Close()
+	var served atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+	}))
+	defer healthy.Close()
+
+	for _, tt := range []struct {
+		method   string
+		want     int
+		replayed int32
+	}{
+		{http.MethodPost, http.StatusBadGateway, 0},
+		{http.MethodPut, http.StatusOK, 1},
+	} {
+		lb := &LoadBalancer{maxRetries: 1}
+		for _, rawURL := range []string{hangup.URL, healthy.URL} {
+			backend, err := newBackend(rawURL)
+			if err != nil {
+				t.Fatalf("newBackend() error = %v", err)
+			}
+			lb.AddBackend(backend)
+		}
+		lb.current = uint64(len(lb.backends) - 1)
+		served.Store(0)
+
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", strings.NewReader("payload")))
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %v, want %v", tt.method, rec.Code, tt.want)
+		}
+		if served.Load() != tt.replayed {
+			t.Errorf("%s: healthy backend got %d requests, want %d", tt.method, served.Load(), tt.replayed)
+		}
+	}
+}
+
+func TestServeHTTPLargeBody(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer echo.Close()
+
+	lb := &LoadBalancer{maxRetries: 1}
+	lb.AddBackend(newTestBackend(t, echo.URL))
+
+	payload := strings.Repeat("x", maxReplayBody+1000)
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != payload {
+		t.Errorf("backend got %d bytes, want %d", rec.Body.Len(), len(payload))
+	}
+}
+
+func TestServeHTTPStreamsBodyWithoutRetries(t *testing.T) {
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, len("hello"))
+		io.ReadFull(r.Body, chunk)
+		received <- string(chunk)
+		io.ReadAll(r.Body)
+	}))
+	defer backend.Close()
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, backend.URL))
+
+	body, client := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", body))
+	}()
+	go client.Write([]byte("hello"))
+
+	// The backend sees the start of the body while the client is still
+	// sending, so nothing is buffered
+	select {
+	case chunk := <-received:
+		if chunk != "hello" {
+			t.Errorf("backend read %q, want %q", chunk, "hello")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("backend got nothing before the body was complete")
+	}
+	client.Close()
+	<-done
+}
+
+func TestServeHTTPSkipsSaturatedBackend(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fast")
+	}))
+	defer fast.Close()
+
+	lb := &LoadBalancer{strategy: &WeightedRoundRobin{}}
+	slowBackend := newTestBackend(t, slow.URL)
+	slowBackend.MaxConns = 1
+	fastBackend := newTestBackend(t, fast.URL)
+	lb.AddBackend(slowBackend)
+	lb.AddBackend(fastBackend)
+
+	// The first pick goes to the slow backend and holds its only slot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+			t.Errorf("request %d: got %v %q, want 200 %q", i, rec.Code, rec.Body.String(), "fast")
+		}
+	}
+
+	// With every backend saturated there is nowhere to send the request
+	fastBackend.MaxConns = 1
+	if !fastBackend.acquire() {
+		t.Fatal("acquire() = false on an idle backend")
+	}
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	fastBackend.release()
+
+	close(unblock)
+	<-done
+	if n := slowBackend.active.Load(); n != 0 {
+		t.Errorf("slow backend active = %d after its request finished, want 0", n)
+	}
+}
+
+// abortingServer starts a backend that sends part of a response body and then
+// drops the connection.
+func abortingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		io.WriteString(w, "partial")
+		http.NewResponseController(w).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServeHTTPAbortedResponseReleasesSlot(t *testing.T) {
+	backend := newTestBackend(t, abortingServer(t).URL)
+	backend.MaxConns = 1
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+	lbServer := httptest.NewServer(lb)
+	defer lbServer.Close()
+
+	// Every request reaches the backend and is cut off, rather than being
+	// turned away because an earlier one still holds the only slot
+	for i := 0; i < 3; i++ {
+		waitIdle(t, backend)
+		resp, err := http.Get(lbServer.URL)
+		if err != nil {
+			continue
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			t.Errorf("request %d: status = %v, want the aborted backend response", i, resp.StatusCode)
+		}
+	}
+	waitIdle(t, backend)
+	if n := backend.requests.Load(); n != 3 {
+		t.Errorf("backend got %d requests, want 3", n)
+	}
+}
+
+// waitIdle waits for backend to have no requests in flight. The proxy's
+// handler may still be unwinding when the client sees the response end.
+func waitIdle(t *testing.T, backend *Backend) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.active.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("backend active = %d, want 0", backend.active.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeHTTPRequestTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+			io.WriteString(w, "too late")
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	lb := &LoadBalancer{maxRetries: 2, RequestTimeout: 50 * time.Millisecond}
+	backend, err := newBackend(slow.URL)
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	lb.AddBackend(backend)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %v, want %v", rec.Code, http.StatusGatewayTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %v, want it cut off near the timeout", elapsed)
+	}
+	// A slow backend stays in rotation and the request is not retried
+	if !backend.IsAlive() {
+		t.Errorf("backend marked down after a timeout, want it alive")
+	}
+	if n := backend.requests.Load(); n != 1 {
+		t.Errorf("backend requests = %d, want 1", n)
+	}
+}
+
+func TestHealthCheckPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	backend := newTestBackend(t, server.URL)
+	backend.SetAlive(false)
+
+	lb := &LoadBalancer{HealthCheckPath: "/healthz"}
+	lb.AddBackend(backend)
+
+	lb.checkBackends("")
+	if !backend.IsAlive() {
+		t.Errorf("backend DOWN, want UP when %s answers 200", lb.HealthCheckPath)
+	}
+
+	// The backend root answers 404, so the default path marks it down
+	lb.HealthCheckPath = ""
+	lb.checkBackends("")
+	if backend.IsAlive() {
+		t.Errorf("backend UP, want DOWN when / answers 404")
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	lb := &LoadBalancer{HealthCheckTimeout: 100 * time.Millisecond}
+	slowBackend := newTestBackend(t, slow.URL)
+	healthyBackend := newTestBackend(t, healthy.URL)
+	healthyBackend.SetAlive(false)
+	lb.AddBackend(slowBackend)
+	lb.AddBackend(healthyBackend)
+
+	start := time.Now()
+	lb.checkBackends("")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("checkBackends() took %v, want it bounded by the probe timeout", elapsed)
+	}
+
+	if slowBackend.IsAlive() {
+		t.Errorf("slow backend UP, want DOWN after the probe timed out")
+	}
+	if !healthyBackend.IsAlive() {
+		t.Errorf("healthy backend DOWN, want UP")
+	}
+}
+
+func TestHealthCheckThresholds(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	backend := newTestBackend(t, server.URL)
+	lb := &LoadBalancer{UnhealthyThreshold: 3, HealthyThreshold: 2}
+	lb.AddBackend(backend)
+
+	// Failures below the threshold leave the backend in rotation
+	for i := 1; i < 3; i++ {
+		lb.checkBackends("")
+		if !backend.IsAlive() {
+			t.Fatalf("backend DOWN after %d failed probes, want UP until 3", i)
+		}
+	}
+	lb.checkBackends("")
+	if backend.IsAlive() {
+		t.Fatalf("backend UP after 3 failed probes, want DOWN")
+	}
+
+	healthy.Store(true)
+	lb.checkBackends("")
+	if backend.IsAlive() {
+		t.Fatalf("backend UP after 1 passed probe, want DOWN until 2")
+	}
+	lb.checkBackends("")
+	if !backend.IsAlive() {
+		t.Errorf("backend DOWN after 2 passed probes, want UP")
+	}
+}
+
+func TestProxyErrorResetsHealthyStreak(t *testing.T) {
+	// Passes health checks but hangs up on proxied requests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	backend, err := newBackend(server.URL)
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	lb := &LoadBalancer{HealthCheckPath: "/health", HealthyThreshold: 2}
+	lb.AddBackend(backend)
+
+	lb.checkBackends("")
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if backend.IsAlive() {
+		t.Fatalf("backend still alive after a proxy error, want it marked down")
+	}
+
+	// The probe before the proxy error does not count towards the threshold
+	lb.checkBackends("")
+	if backend.IsAlive() {
+		t.Fatalf("backend UP after 1 passed probe since the proxy error, want DOWN until 2")
+	}
+	lb.checkBackends("")
+	if !backend.IsAlive() {
+		t.Errorf("backend DOWN after 2 passed probes, want UP")
+	}
+}
+
+func TestProxySetsForwardedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+			w.Header().Set("Echo-"+name, r.Header.Get(name))
+		}
+	}))
+	defer server.Close()
+
+	backend, err := newBackend(server.URL)
+	if err != nil {
+		t.Fatalf("newBackend() error = %v", err)
+	}
+	lb := &LoadBalancer{}
+	lb.AddBackend(backend)
+
+	req := httptest.NewRequest(http.MethodGet, "http://lb.example.com/", nil)
+	req.RemoteAddr = "203.0.113.9:40000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"X-Forwarded-For":   "198.51.100.1, 203.0.113.9", // The existing chain is preserved
+		"X-Forwarded-Host":  "lb.example.com",
+		"X-Forwarded-Proto": "http",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get("Echo-" + name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "slow")
+		finished.Store(true)
+	}))
+	defer backendServer.Close()
+
+	lb := &LoadBalancer{}
+	lb.AddBackend(newTestBackend(t, backendServer.URL))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	adminLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() { serveDone <- serve(ctx, lb, ln, adminLn) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-serveDone:
+		if err != nil {
+			t.Fatalf("serve() error = %v", err)
+		}
+		if !finished.Load() {
+			t.Fatal("serve() returned before the in-flight request completed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() did not return after shutdown")
+	}
+
+	select {
+	case res := <-responses:
+		if res.err != nil {
+			t.Fatalf("In-flight request failed: %v", res.err)
+		}
+		if res.body != "slow" {
+			t.Errorf("In-flight response body = %q, want %q", res.body, "slow")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("In-flight request did not complete")
+	}
+}
+
+// dialUpgrade sends an upgrade handshake for protocol to the server at addr
+// and returns the connection along with the server's response.
+func dialUpgrade(t *testing.T, addr, protocol string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /chat HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", addr, protocol)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	return conn, br, resp
+}
+
+func TestServeHTTPUpgrade(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "expected an echo upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "echo: "+line)
+		}
+	}))
+	defer backendServer.Close()
+
+	// The connection must outlive RequestTimeout, which bounds plain requests
+	lb := &LoadBalancer{RequestTimeout: 50 * time.Millisecond}
+	backend := newTestBackend(t, backendServer.URL)
+	lb.AddBackend(backend)
+	lbServer := httptest.NewServer(lb)
+	defer lbServer.Close()
+
+	conn, br, resp := dialUpgrade(t, lbServer.Listener.Addr().String(), "echo")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %v, want %v", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	for i, msg := range []string{"hello", "world"} {
+		if i > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Fprintf(conn, "%s\n", msg)
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read message %d: %v", i, err)
+		}
+		if want := "echo: " + msg + "\n"; line != want {
+			t.Errorf("message %d = %q, want %q", i, line, want)
+		}
+	}
+
+	// Once the client hangs up the proxy releases the backend, without
+	// counting the connection's lifetime as a response time
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.active.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("backend still active after the client closed the connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := backend.responseTime.Value(); ok {
+		t.Errorf("upgraded connection was recorded as a response time")
+	}
+}
+
+func TestServeHTTPUpgradeRefused(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain")
+	}))
+	defer backendServer.Close()
+
+	lb := &LoadBalancer{}
+	backend := newTestBackend(t, backendServer.URL)
+	lb.AddBackend(backend)
+	lbServer := httptest.NewServer(lb)
+	defer lbServer.Close()
+
+	_, _, resp := dialUpgrade(t, lbServer.Listener.Addr().String(), "websocket")
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "plain" {
+		t.Errorf("got %v %q, want the backend's own 200 %q", resp.StatusCode, body, "plain")
+	}
+	if !backend.IsAlive() || backend.errors.Load() != 0 {
+		t.Errorf("backend refusing an upgrade should stay up without errors")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy picks the backend that serves a request, or nil when none is
+// available.
+type Strategy interface {
+	Next(backends []*Backend, r *http.Request) *Backend
+}
+
+// WeightedRoundRobin spreads requests across alive backends in proportion to
+// their Weight. It uses smooth weighted round-robin, which interleaves picks
+// instead of sending a burst to the heaviest backend. Backends with weight 0
+// receive no traffic, and saturated backends are passed over.
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[*Backend]int
+}
+
+// Next returns the alive backend whose running weight is highest and lowers
+// that weight by the total, so each backend is picked Weight times per cycle.
+func (s *WeightedRoundRobin) Next(backends []*Backend, r *http.Request) *Backend {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		s.current = make(map[*Backend]int)
+	}
+	s.prune(backends)
+
+	var best *Backend
+	total := 0
+	for _, backend := range backends {
+		if backend.Weight <= 0 || !backend.available() {
+			continue
+		}
+		s.current[backend] += backend.Weight
+		total += backend.Weight
+		if best == nil || s.current[backend] > s.current[best] {
+			best = backend
+		}
+	}
+
+	if best != nil {
+		s.current[best] -= total
+	}
+	return best
+}
+
+// prune drops the running weights of backends that are no longer in
+// backends, so a removed backend isn't kept alive by the map. The caller must
+// hold s.mu.
+func (s *WeightedRoundRobin) prune(backends []*Backend) {
+	present := 0
+	for _, backend := range backends {
+		if _, ok := s.current[backend]; ok {
+			present++
+		}
+	}
+	if present == len(s.current) {
+		return
+	}
+
+	for backend := range s.current {
+		if !slices.Contains(backends, backend) {
+			delete(s.current, backend)
+		}
+	}
+}
+
+// IPHash routes each client to the same backend by hashing its IP address,
+// for backends that keep per-session state. When that backend is down or
+// saturated the client moves to the next available one.
+type IPHash struct{}
+
+// Next returns the backend the client IP of r hashes to
+func (IPHash) Next(backends []*Backend, r *http.Request) *Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+
+	start := int(h.Sum32() % uint32(len(backends)))
+	for i := range backends {
+		backend := backends[(start+i)%len(backends)]
+		if backend.available() {
+			return backend
+		}
+	}
+	return nil
+}
+
+// Random picks a uniformly random available backend. It keeps no state
+// between requests, so unlike round-robin there is no shared counter for
+// concurrent requests to contend on.
+type Random struct{}
+
+// Next returns a random available backend, chosen by reservoir sampling so
+// the backends are walked only once
+func (Random) Next(backends []*Backend, r *http.Request) *Backend {
+	var picked *Backend
+	seen := 0
+	for _, backend := range backends {
+		if !backend.available() {
+			continue
+		}
+		seen++
+		if rand.IntN(seen) == 0 {
+			picked = backend
+		}
+	}
+	return picked
+}
+
+// probeInterval is how often LeastResponseTime sends a request to a backend
+// other than the fastest one.
+const probeInterval = 10
+
+// LeastResponseTime sends requests to the available backend with the lowest
+// moving average response time. Every probeInterval-th request goes to another
+// backend instead so its average stays current: one without samples, such as
+// one just added or brought back up, or else one of the slower backends, which
+// may have sped up since it was last measured.
+type LeastResponseTime struct {
+	picks atomic.Uint64
+}
+
+// Next returns the fastest available backend, or another one when it is time
+// to probe
+func (s *LeastResponseTime) Next(backends []*Backend, r *http.Request) *Backend {
+	pick := s.picks.Add(1) - 1
+
+	var fastest *Backend
+	var fastestTime time.Duration
+	var cold, warm []*Backend
+	for _, backend := range backends {
+		if !backend.available() {
+			continue
+		}
+		rt, ok := backend.responseTime.Value()
+		if !ok {
+			cold = append(cold, backend)
+			continue
+		}
+		warm = append(warm, backend)
+		if fastest == nil || rt < fastestTime {
+			fastest, fastestTime = backend, rt
+		}
+	}
+
+	if len(cold) > 0 && (fastest == nil || pick%probeInterval == 0) {
+		return cold[pick%uint64(len(cold))]
+	}
+	if len(warm) > 1 && pick%probeInterval == 0 {
+		slower := make([]*Backend, 0, len(warm)-1)
+		for _, backend := range warm {
+			if backend != fastest {
+				slower = append(slower, backend)
+			}
+		}
+		return slower[(pick/probeInterval)%uint64(len(slower))]
+	}
+	return fastest
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWeightedRoundRobinDistribution(t *testing.T) {
+	heavy := newTestBackend(t, "http://localhost:3001")
+	heavy.Weight = 3
+	light := newTestBackend(t, "http://localhost:3002")
+	light.Weight = 1
+	down := newTestBackend(t, "http://localhost:3003")
+	down.Weight = 5
+	down.SetAlive(false)
+
+	backends := []*Backend{heavy, light, down}
+	strategy := &WeightedRoundRobin{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := make(map[*Backend]int)
+	for i := 0; i < 400; i++ {
+		counts[strategy.Next(backends, req)]++
+	}
+
+	if counts[heavy] != 300 || counts[light] != 100 {
+		t.Errorf("distribution = %d:%d, want 300:100", counts[heavy], counts[light])
+	}
+	if counts[down] != 0 {
+		t.Errorf("dead backend got %d requests, want 0", counts[down])
+	}
+}
+
+func TestWeightedRoundRobinInterleaves(t *testing.T) {
+	heavy := newTestBackend(t, "http://localhost:3001")
+	heavy.Weight = 3
+	light := newTestBackend(t, "http://localhost:3002")
+	light.Weight = 1
+
+	strategy := &WeightedRoundRobin{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Smooth WRR never sends the light backend two requests in a row
+	var previous *Backend
+	for i := 0; i < 40; i++ {
+		next := strategy.Next([]*Backend{heavy, light}, req)
+		if next == light && previous == light {
+			t.Fatalf("light backend picked twice in a row at request %d", i)
+		}
+		previous = next
+	}
+}
+
+func TestWeightedRoundRobinZeroWeight(t *testing.T) {
+	active := newTestBackend(t, "http://localhost:3001")
+	drained := newTestBackend(t, "http://localhost:3002")
+	drained.Weight = 0
+
+	strategy := &WeightedRoundRobin{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 10; i++ {
+		if next := strategy.Next([]*Backend{active, drained}, req); next != active {
+			t.Fatalf("Next() = %v, want only the weighted backend", next.URL)
+		}
+	}
+
+	if next := strategy.Next([]*Backend{drained}, req); next != nil {
+		t.Errorf("Next() = %v, want nil when every backend has weight 0", next.URL)
+	}
+}
+
+func TestWeightedRoundRobinDropsRemovedBackends(t *testing.T) {
+	kept := newTestBackend(t, "http://localhost:3001")
+	removed := newTestBackend(t, "http://localhost:3002")
+	added := newTestBackend(t, "http://localhost:3003")
+
+	strategy := &WeightedRoundRobin{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	strategy.Next([]*Backend{kept, removed}, req)
+
+	// One backend swapped for another leaves the count unchanged
+	strategy.Next([]*Backend{kept, added}, req)
+	if _, ok := strategy.current[removed]; ok {
+		t.Errorf("removed backend still has a running weight")
+	}
+	if len(strategy.current) != 2 {
+		t.Errorf("len(current) = %d, want 2", len(strategy.current))
+	}
+}
+
+func TestServeHTTPUsesStrategy(t *testing.T) {
+	var hits [2]int
+	servers := make([]*httptest.Server, 2)
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i]++
+		}))
+		defer servers[i].Close()
+	}
+
+	lb := &LoadBalancer{strategy: &WeightedRoundRobin{}}
+	heavy := newTestBackend(t, servers[0].URL)
+	heavy.Weight = 3
+	lb.AddBackend(heavy)
+	lb.AddBackend(newTestBackend(t, servers[1].URL))
+
+	for i := 0; i < 8; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	if hits[0] != 6 || hits[1] != 2 {
+		t.Errorf("hits = %v, want [6 2]", hits)
+	}
+}
+
+func TestIPHashSticky(t *testing.T) {
+	backends := []*Backend{
+		newTestBackend(t, "http://localhost:3001"),
+		newTestBackend(t, "http://localhost:3002"),
+		newTestBackend(t, "http://localhost:3003"),
+	}
+	strategy := IPHash{}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:40000"
+	first := strategy.Next(backends, req)
+	if first == nil {
+		t.Fatalf("Next() = nil, want a backend")
+	}
+
+	for port := 40001; port < 40010; port++ {
+		// Only the IP counts, so new connections from the client stick too
+		req.RemoteAddr = "203.0.113.7:" + strconv.Itoa(port)
+		if next := strategy.Next(backends, req); next != first {
+			t.Fatalf("Next() = %v for %s, want %v", next.URL, req.RemoteAddr, first.URL)
+		}
+	}
+}
+
+func TestIPHashFallsBackWhenDown(t *testing.T) {
+	backends := []*Backend{
+		newTestBackend(t, "http://localhost:3001"),
+		newTestBackend(t, "http://localhost:3002"),
+		newTestBackend(t, "http://localhost:3003"),
+	}
+	strategy := IPHash{}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.20:1234"
+	chosen := strategy.Next(backends, req)
+	chosen.SetAlive(false)
+
+	fallback := strategy.Next(backends, req)
+	if fallback == nil || fallback == chosen {
+		t.Fatalf("Next() = %v after the chosen backend went down, want another alive backend", fallback)
+	}
+	if again := strategy.Next(backends, req); again != fallback {
+		t.Errorf("Next() = %v, want the client to stay on fallback %v", again.URL, fallback.URL)
+	}
+
+	for _, backend := range backends {
+		backend.SetAlive(false)
+	}
+	if next := strategy.Next(backends, req); next != nil {
+		t.Errorf("Next() = %v, want nil when every backend is down", next.URL)
+	}
+}
+
+func TestRandomSkipsDeadBackends(t *testing.T) {
+	var alive []*Backend
+	for i := 0; i < 3; i++ {
+		alive = append(alive, newTestBackend(t, "http://localhost:300"+strconv.Itoa(i)))
+	}
+	down := newTestBackend(t, "http://localhost:3009")
+	down.SetAlive(false)
+
+	backends := []*Backend{alive[0], down, alive[1], alive[2]}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := make(map[*Backend]int)
+	for i := 0; i < 300; i++ {
+		counts[Random{}.Next(backends, req)]++
+	}
+
+	for i, backend := range alive {
+		if counts[backend] == 0 {
+			t.Errorf("alive backend %d was never picked", i)
+		}
+	}
+	if counts[down] != 0 {
+		t.Errorf("dead backend got %d requests, want 0", counts[down])
+	}
+	if counts[nil] != 0 {
+		t.Errorf("Next() returned nil %d times with alive backends", counts[nil])
+	}
+
+	for _, backend := range alive {
+		backend.SetAlive(false)
+	}
+	if got := (Random{}).Next(backends, req); got != nil {
+		t.Errorf("Next() with no alive backends = %v, want nil", got.URL)
+	}
+}
+
+func TestLeastResponseTimePrefersFastBackend(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	slowBackend := newTestBackend(t, slow.URL)
+	fastBackend := newTestBackend(t, fast.URL)
+	lb := &LoadBalancer{strategy: &LeastResponseTime{}}
+	lb.AddBackend(slowBackend)
+	lb.AddBackend(fastBackend)
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			rec := httptest.NewRecorder()
+			lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request %d: status = %v, want %v", i, rec.Code, http.StatusOK)
+			}
+		}
+	}
+
+	// Warm up until the cold probes have measured both backends
+	send(2 * probeInterval)
+	if _, ok := fastBackend.responseTime.Value(); !ok {
+		t.Fatal("fast backend was never probed")
+	}
+
+	slowBefore := slowBackend.requests.Load()
+	fastBefore := fastBackend.requests.Load()
+	send(20)
+	// The slow backend only gets the periodic probes
+	probes := uint64(20 / probeInterval)
+	if n := slowBackend.requests.Load() - slowBefore; n != probes {
+		t.Errorf("slow backend got %d of 20 requests after warm-up, want %d", n, probes)
+	}
+	if n := fastBackend.requests.Load() - fastBefore; n != 20-probes {
+		t.Errorf("fast backend got %d of 20 requests after warm-up, want %d", n, 20-probes)
+	}
+}
+
+func TestLeastResponseTimeNoticesRecoveredBackend(t *testing.T) {
+	steady := newTestBackend(t, "http://localhost:3001")
+	steady.responseTime.Observe(time.Millisecond)
+	recovered := newTestBackend(t, "http://localhost:3002")
+	recovered.responseTime.Observe(50 * time.Millisecond)
+
+	strategy := &LeastResponseTime{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	backends := []*Backend{steady, recovered}
+
+	// The once slow backend now answers faster than the other, which only
+	// shows once probes reach it
+	counts := make(map[*Backend]int)
+	for i := 0; i < 30*probeInterval; i++ {
+		picked := strategy.Next(backends, req)
+		switch picked {
+		case steady:
+			steady.responseTime.Observe(time.Millisecond)
+		case recovered:
+			recovered.responseTime.Observe(100 * time.Microsecond)
+		}
+		if i >= 29*probeInterval {
+			counts[picked]++
+		}
+	}
+
+	if counts[recovered] != probeInterval-1 {
+		t.Errorf("recovered backend got %d of the last %d requests, want %d", counts[recovered], probeInterval, probeInterval-1)
+	}
+}
+
+func TestLeastResponseTimeProbesColdBackend(t *testing.T) {
+	warm := newTestBackend(t, "http://localhost:3001")
+	warm.responseTime.Observe(time.Millisecond)
+	cold := newTestBackend(t, "http://localhost:3002")
+
+	strategy := &LeastResponseTime{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := make(map[*Backend]int)
+	for i := 0; i < 10*probeInterval; i++ {
+		counts[strategy.Next([]*Backend{warm, cold}, req)]++
+	}
+
+	if counts[cold] != 10 || counts[warm] != 90 {
+		t.Errorf("distribution = %d:%d (warm:cold), want 90:10", counts[warm], counts[cold])
+	}
+}
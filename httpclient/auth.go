@@ -0,0 +1,63 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// basicCredentials are the user name and password set with WithBasicAuth.
+type basicCredentials struct {
+	username string
+	password string
+}
+
+// WithBasicAuth sends HTTP basic authentication on every request. A bearer
+// token, when one is configured, takes precedence.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.basicAuth = &basicCredentials{username: username, password: password}
+	}
+}
+
+// WithTokenRefresh sets a callback that supplies bearer tokens, for tokens
+// that expire. It is called before the first request when Config.BearerToken
+// is empty, and again whenever a request is answered with 401 Unauthorized,
+// after which the request is sent once more with the new token.
+func WithTokenRefresh(refresh func() (string, error)) Option {
+	return func(c *Client) {
+		c.refreshToken = refresh
+	}
+}
+
+// authorize sets the Authorization header on req from the configured
+// credentials. With refresh set, a new bearer token is fetched first.
+func (c *Client) authorize(req *http.Request, refresh bool) error {
+	token, err := c.token(refresh)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.basicAuth != nil:
+		req.SetBasicAuth(c.basicAuth.username, c.basicAuth.password)
+	}
+	return nil
+}
+
+// token returns the current bearer token, asking refreshToken for a new one
+// when forced to or when there is none yet.
+func (c *Client) token(refresh bool) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.refreshToken != nil && (refresh || c.bearerToken == "") {
+		token, err := c.refreshToken()
+		if err != nil {
+			return "", fmt.Errorf("httpclient: failed to refresh token: %w", err)
+		}
+		c.bearerToken = token
+	}
+	return c.bearerToken, nil
+}
@@ -0,0 +1,89 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+// newAuthServer echoes the Authorization header it receives, answering 401
+// when it differs from valid.
+func newAuthServer(t *testing.T, valid string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth != valid {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		w.Write([]byte(auth))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBearerToken(t *testing.T) {
+	server := newAuthServer(t, "Bearer secret")
+	client := httpclient.New(httpclient.Config{
+		BaseURL:     server.URL,
+		BearerToken: "secret",
+	})
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v (Authorization %q)", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	// "alice:wonderland" in base64
+	server := newAuthServer(t, "Basic YWxpY2U6d29uZGVybGFuZA==")
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL},
+		httpclient.WithBasicAuth("alice", "wonderland"))
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v (Authorization %q)", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	server := newAuthServer(t, "Bearer token-2")
+
+	refreshes := 0
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL},
+		httpclient.WithTokenRefresh(func() (string, error) {
+			refreshes++
+			return "token-" + strconv.Itoa(refreshes), nil
+		}))
+
+	// token-1 is fetched up front and rejected, so token-2 is fetched and used
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v (Authorization %q)", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+	if refreshes != 2 {
+		t.Errorf("refresh called %d times, want 2", refreshes)
+	}
+
+	// The refreshed token is reused
+	if _, err := client.Get(context.Background(), "/"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if refreshes != 2 {
+		t.Errorf("refresh called %d times after a second request, want 2", refreshes)
+	}
+}
@@ -1,33 +1,798 @@
 package httpclient
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	httpClient *http.Client
+	transport  *http.Transport
+	configErr  error // returned by every request when New could not apply Config
 	baseURL    string
+	baseURLs   []string
+	current    atomic.Int64 // index into baseURLs of the last healthy base URL
 	headers    map[string]string
+
+	retryCount         int
+	retryBackoff       time.Duration // delay before the first retry, doubled for each further one
+	retryNonIdempotent bool
+	maxRetryAfter      time.Duration // cap on waits requested by Retry-After
+
+	tokenMu      sync.Mutex // guards bearerToken, which refreshToken replaces
+	bearerToken  string
+	refreshToken func() (string, error)
+	basicAuth    *basicCredentials
+
+	onRequest        func(*http.Request)
+	onResponse       func(*http.Response, time.Duration)
+	limiter          *tokenBucket // nil without a rate limit
+	compress         bool
+	errorOnHTTPError bool
+	checkContentType bool
 }
 
 type Config struct {
-	Timeout    time.Duration
-	BaseURL    string
-	Headers    map[string]string
-	RetryCount int
+	Timeout     time.Duration
+	BaseURL     string
+	Headers     map[string]string
+	RetryCount  int
+	BearerToken string // sent as "Authorization: Bearer <token>" on every request
+	Middlewares []Middleware
+	Compress    bool // gzip request bodies, sending Content-Encoding: gzip
+
+	// DisableRedirects returns 3xx responses as they are instead of following
+	// them. Otherwise up to MaxRedirects redirects are followed, 10 if unset.
+	DisableRedirects bool
+	MaxRedirects     int
+
+	Transport TransportConfig
+
+	// RateLimit caps outbound requests per second, letting RateBurst through
+	// at once (1 if unset). Zero means no limit.
+	RateLimit float64
+	RateBurst int
+
+	// Proxy is the URL of an HTTP or HTTPS proxy to send every request
+	// through. Without one, ProxyFromEnvironment uses the proxy named by the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables; otherwise requests go
+	// out directly.
+	Proxy                string
+	ProxyFromEnvironment bool
+
+	// OnRequest and OnResponse observe every request sent, retries included,
+	// for metrics and tracing. They must not modify what they are given; use
+	// Middlewares for that. OnResponse gets the time from sending the request
+	// to reading the whole response, and is not called when the request
+	// fails without a response.
+	OnRequest  func(*http.Request)
+	OnResponse func(*http.Response, time.Duration)
+
+	// TLSConfig replaces the default TLS configuration, which requires TLS
+	// 1.2. CACertPath names a PEM file of CAs to trust instead of the system
+	// roots, and ClientCertPath and ClientKeyPath a PEM key pair presented for
+	// mutual TLS. A file that fails to load makes every request fail.
+	TLSConfig      *tls.Config
+	CACertPath     string
+	ClientCertPath string
+	ClientKeyPath  string
+
+	// ErrorOnHTTPError turns responses with a status of 400 or above into an
+	// *HTTPError carrying the status and body.
+	ErrorOnHTTPError bool
+}
+
+// TransportConfig tunes the client's connection pool. Zero fields keep the
+// defaults of http.DefaultTransport.
+type TransportConfig struct {
+	MaxIdleConns        int           // idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // idle connections kept per host
+	IdleConnTimeout     time.Duration // how long an idle connection is kept
+	DisableKeepAlives   bool          // use each connection for one request only
+}
+
+// apply sets the configured values on transport.
+func (tc TransportConfig) apply(transport *http.Transport) {
+	if tc.MaxIdleConns > 0 {
+		transport.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	if tc.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = tc.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = tc.DisableKeepAlives
+}
+
+// defaultMaxRedirects matches the limit of http.Client's default policy.
+const defaultMaxRedirects = 10
+
+// ErrTooManyRedirects is matched by errors.Is when a request was redirected
+// more than Config.MaxRedirects times.
+var ErrTooManyRedirects = errors.New("httpclient: too many redirects")
+
+// Option customizes a Client beyond what Config covers.
+type Option func(*Client)
+
+const (
+	defaultRetryBackoff  = 100 * time.Millisecond
+	maxRetryBackoff      = 10 * time.Second
+	defaultMaxRetryAfter = 30 * time.Second
+)
+
+// WithRetryBackoff sets the delay before the first retry. Each further retry
+// waits twice as long, with jitter, up to 10s. The default is 100ms.
+func WithRetryBackoff(d time.Duration) Option {
+	return func(c *Client) {
+		c.retryBackoff = d
+	}
+}
+
+// WithMaxRetryAfter caps how long the client waits when a 429 or 503
+// response asks it to retry later with a Retry-After header. The default is
+// 30s.
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetryAfter = d
+	}
+}
+
+// WithRetryNonIdempotent controls whether POST and PATCH requests are retried
+// too. They are not by default, since the server may have acted on a request
+// whose response was lost.
+func WithRetryNonIdempotent(enabled bool) Option {
+	return func(c *Client) {
+		c.retryNonIdempotent = enabled
+	}
+}
+
+// WithBaseURLs sets a pool of base URLs to fail over across. Requests go to the
+// last healthy base URL first and move on to the next one on connection errors
+// or 5xx responses.
+func WithBaseURLs(urls ...string) Option {
+	return func(c *Client) {
+		c.baseURLs = append([]string(nil), urls...)
+	}
+}
+
+// WithMinTLSVersion sets the lowest TLS version the client will negotiate,
+// e.g. tls.VersionTLS13. The default is TLS 1.2.
+func WithMinTLSVersion(version uint16) Option {
+	return func(c *Client) {
+		c.transport.TLSClientConfig.MinVersion = version
+	}
+}
+
+// WithCipherSuites restricts the TLS 1.0-1.2 cipher suites the client offers
+// to the given allowlist. TLS 1.3 suites are not configurable.
+func WithCipherSuites(suites ...uint16) Option {
+	return func(c *Client) {
+		c.transport.TLSClientConfig.CipherSuites = append([]uint16(nil), suites...)
+	}
+}
+
+// Response is a fully read HTTP response.
+type Response struct {
+	StatusCode int
+	Status     string // e.g. "404 Not Found"
+	Header     http.Header
+	Body       []byte
+
+	checkContentType bool
 }
 
-func New(cfg Config) *Client {
+// IsSuccess reports whether the response has a 2xx status.
+func (r *Response) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// HTTPError is returned for responses with a status of 400 or above when
+// Config.ErrorOnHTTPError is set.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	snippet := e.Body
+	if len(snippet) > snippetSize {
+		snippet = snippet[:snippetSize]
+	}
+	return fmt.Sprintf("httpclient: unexpected status %s: %q", e.Status, snippet)
+}
+
+func New(cfg Config, opts ...Option) *Client {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 30 * time.Second
 	}
 
-	return &Client{
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsCfg, configErr := tlsConfig(cfg)
+	if configErr != nil {
+		tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	transport.TLSClientConfig = tlsCfg
+	cfg.Transport.apply(transport)
+	transport.Proxy = proxyFunc(cfg)
+
+	c := &Client{
 		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
+			Timeout:       cfg.Timeout,
+			Transport:     chain(transport, cfg.Middlewares),
+			CheckRedirect: redirectPolicy(cfg),
 		},
-		baseURL: cfg.BaseURL,
-		headers: cfg.Headers,
+		transport: transport,
+		configErr: configErr,
+		baseURL:   cfg.BaseURL,
+		headers:   cfg.Headers,
+
+		retryCount:    cfg.RetryCount,
+		retryBackoff:  defaultRetryBackoff,
+		maxRetryAfter: defaultMaxRetryAfter,
+
+		bearerToken: cfg.BearerToken,
+
+		onRequest:        cfg.OnRequest,
+		onResponse:       cfg.OnResponse,
+		compress:         cfg.Compress,
+		errorOnHTTPError: cfg.ErrorOnHTTPError,
+		checkContentType: true,
+	}
+
+	if cfg.RateLimit > 0 {
+		c.limiter = newTokenBucket(cfg.RateLimit, cfg.RateBurst)
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// NewWithError is New for callers that want configuration mistakes reported
+// up front: it validates cfg and any base URLs set by options, and returns
+// the error from loading TLS files instead of failing every request.
+func NewWithError(cfg Config, opts ...Option) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := New(cfg, opts...)
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+	for _, base := range c.baseURLs {
+		if err := validateBaseURL(base); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Validate checks that cfg has a usable BaseURL and Proxy and no negative
+// durations or counts.
+func (cfg Config) Validate() error {
+	if cfg.BaseURL != "" {
+		if err := validateBaseURL(cfg.BaseURL); err != nil {
+			return err
+		}
+	}
+	if cfg.Proxy != "" {
+		if _, err := parseProxyURL(cfg.Proxy); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case cfg.Timeout < 0:
+		return fmt.Errorf("httpclient: negative Timeout %v", cfg.Timeout)
+	case cfg.RetryCount < 0:
+		return fmt.Errorf("httpclient: negative RetryCount %d", cfg.RetryCount)
+	case cfg.MaxRedirects < 0:
+		return fmt.Errorf("httpclient: negative MaxRedirects %d", cfg.MaxRedirects)
+	case cfg.RateLimit < 0 || cfg.RateBurst < 0:
+		return fmt.Errorf("httpclient: negative RateLimit %v or RateBurst %d", cfg.RateLimit, cfg.RateBurst)
+	}
+	return nil
+}
+
+// validateBaseURL checks that rawURL is an absolute http or https URL.
+func validateBaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("httpclient: invalid base URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("httpclient: base URL %q must use http or https", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("httpclient: base URL %q has no host", rawURL)
+	}
+	return nil
+}
+
+// proxyFunc builds the transport Proxy function for cfg. An invalid Proxy
+// URL fails every request rather than silently bypassing the proxy.
+func proxyFunc(cfg Config) func(*http.Request) (*url.URL, error) {
+	switch {
+	case cfg.Proxy != "":
+		proxyURL, err := parseProxyURL(cfg.Proxy)
+		if err != nil {
+			return func(*http.Request) (*url.URL, error) { return nil, err }
+		}
+		return http.ProxyURL(proxyURL)
+	case cfg.ProxyFromEnvironment:
+		return http.ProxyFromEnvironment
+	}
+	return nil
+}
+
+// parseProxyURL parses a Config.Proxy value.
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err == nil && proxyURL.Host == "" {
+		err = errors.New("missing host")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid proxy URL %q: %w", rawURL, err)
+	}
+	return proxyURL, nil
+}
+
+// redirectPolicy builds the http.Client CheckRedirect function for cfg.
+func redirectPolicy(cfg Config) func(*http.Request, []*http.Request) error {
+	if cfg.DisableRedirects {
+		return func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	maxRedirects := cfg.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// CurrentBaseURL returns the base URL that requests are currently sent to first.
+func (c *Client) CurrentBaseURL() string {
+	if len(c.baseURLs) == 0 {
+		return c.baseURL
+	}
+	return c.baseURLs[int(c.current.Load())%len(c.baseURLs)]
+}
+
+// Get sends a GET request to path relative to the base URL.
+func (c *Client) Get(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodGet, path, nil, opts)
+}
+
+// GetWithParams sends a GET request to path with params added to its query.
+func (c *Client) GetWithParams(ctx context.Context, path string, params url.Values, opts ...RequestOption) (*Response, error) {
+	path, err := withQuery(path, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodGet, path, nil, opts)
+}
+
+// Post sends a POST request with body to path relative to the base URL.
+func (c *Client) Post(ctx context.Context, path string, body []byte, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodPost, path, body, opts)
+}
+
+// Put sends a PUT request with body to path relative to the base URL.
+func (c *Client) Put(ctx context.Context, path string, body []byte, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodPut, path, body, opts)
+}
+
+// Patch sends a PATCH request with body to path relative to the base URL.
+func (c *Client) Patch(ctx context.Context, path string, body []byte, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodPatch, path, body, opts)
+}
+
+// Delete sends a DELETE request to path relative to the base URL.
+func (c *Client) Delete(ctx context.Context, path string, opts ...RequestOption) (*Response, error) {
+	return c.do(ctx, http.MethodDelete, path, nil, opts)
+}
+
+// RequestOption customizes a single request made with one of the verb methods.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	timeout time.Duration
+}
+
+// WithTimeout bounds this request, retries included, by d. The client's own
+// Timeout still applies to each attempt, so the shorter of the two wins.
+func WithTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = d
+	}
+}
+
+// do builds a request for path relative to the base URL and sends it with Do.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, opts []RequestOption) (*Response, error) {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.Do(req)
+}
+
+// Do sends req with the client's default headers added, except where req
+// already sets them. A req whose URL is relative, such as "/users", goes to
+// the base URLs; an absolute URL is used as is. Connection errors and 5xx
+// responses are retried up to RetryCount times with exponential backoff when
+// the method may be retried, so the request body is buffered up front. req
+// itself is left unchanged, so it can be sent again once its body is reset.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+	req = req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	c.applyHeaders(req)
+
+	if c.compress && len(body) > 0 && req.Header.Get("Content-Encoding") == "" {
+		var err error
+		if body, err = gzipBody(body); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	// Leave credentials the caller set alone, including on a 401
+	ownAuth := req.Header.Get("Authorization") != ""
+	if !ownAuth {
+		if err := c.authorize(req, false); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.retry(req, body)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && !ownAuth && c.refreshToken != nil {
+		if err := c.authorize(req, true); err != nil {
+			return nil, err
+		}
+		resp, err = c.retry(req, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.errorOnHTTPError && resp.StatusCode >= http.StatusBadRequest {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: resp.Body}
+	}
+	return resp, nil
+}
+
+// retry sends req until it gets an answer other than a connection error,
+// 429 or 5xx response, or runs out of the attempts its method allows. A 429
+// or 503 with a Retry-After header waits as long as the server asks, up to
+// maxRetryAfter, instead of the usual backoff.
+func (c *Client) retry(req *http.Request, body []byte) (*Response, error) {
+	ctx := req.Context()
+	attempts := 1
+	if c.retryNonIdempotent || isIdempotent(req.Method) {
+		attempts += max(c.retryCount, 0)
+	}
+
+	var resp *Response
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay, ok := c.retryAfter(resp)
+			if !ok {
+				delay = c.backoff(attempt)
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		if req.URL.IsAbs() {
+			resp, err = c.send(withBody(req.Clone(ctx), body))
+		} else {
+			resp, err = c.failover(req, body)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+	}
+	return resp, err
+}
+
+// retryAfter returns the wait a 429 or 503 response asked for in its
+// Retry-After header, given either in seconds or as an HTTP date.
+func (c *Client) retryAfter(resp *Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	} else {
+		return 0, false
+	}
+	return min(max(delay, 0), c.maxRetryAfter), true
+}
+
+// applyHeaders sets the client's default headers that req does not set itself.
+func (c *Client) applyHeaders(req *http.Request) {
+	for key, value := range c.headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+}
+
+// withBody gives req its own reader over body, so the same buffered body can
+// be sent on every attempt.
+func withBody(req *http.Request, body []byte) *http.Request {
+	if body == nil {
+		req.Body = nil
+		req.GetBody = nil
+		req.ContentLength = 0
+		return req
 	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return req
+}
+
+// isIdempotent reports whether repeating a request with method has the same
+// effect as sending it once (RFC 9110, section 9.2.2).
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry: retryBackoff
+// doubled for each earlier retry, capped, with the upper half randomized so
+// clients that failed together do not retry in lockstep.
+func (c *Client) backoff(retry int) time.Duration {
+	d := c.retryBackoff
+	for i := 1; i < retry && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// failover sends req, whose URL is relative, to each base URL in turn,
+// starting from the current healthy one, until one answers without a
+// connection error or 5xx status. A 5xx only moves a non-idempotent request
+// on when WithRetryNonIdempotent is set, since the server may already have
+// acted on it; a connection error always does.
+func (c *Client) failover(req *http.Request, body []byte) (*Response, error) {
+	ctx := req.Context()
+	bases := c.baseURLs
+	if len(bases) == 0 {
+		bases = []string{c.baseURL}
+	}
+
+	start := int(c.current.Load())
+	repeatable := c.retryNonIdempotent || isIdempotent(req.Method)
+	var lastResp *Response
+	var lastErr error
+
+	for i := range bases {
+		idx := (start + i) % len(bases)
+
+		rawURL, err := joinURL(bases[idx], req.URL.String())
+		if err != nil {
+			return nil, err
+		}
+		target, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		attempt := withBody(req.Clone(ctx), body)
+		attempt.URL = target
+		attempt.Host = ""
+
+		resp, err := c.send(attempt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastResp, lastErr = nil, err
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			if !repeatable {
+				return resp, nil
+			}
+			lastResp, lastErr = resp, nil
+			continue
+		}
+
+		c.current.Store(int64(idx))
+		return resp, nil
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// Race sends the same request to every URL concurrently and returns the first
+// 2xx response, cancelling the requests still in flight. body, if non-nil, is
+// called once per URL so each request gets its own reader.
+func (c *Client) Race(ctx context.Context, method string, urls []string, body func() io.Reader) (*Response, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("httpclient: no URLs to race")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	results := make(chan result, len(urls))
+
+	for _, rawURL := range urls {
+		var reader io.Reader
+		if body != nil {
+			reader = body()
+		}
+		go func(rawURL string, reader io.Reader) {
+			req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+			if err != nil {
+				results <- result{err: fmt.Errorf("failed to create request: %w", err)}
+				return
+			}
+			c.applyHeaders(req)
+			if req.Header.Get("Authorization") == "" {
+				if err := c.authorize(req, false); err != nil {
+					results <- result{err: err}
+					return
+				}
+			}
+
+			resp, err := c.send(req)
+			results <- result{resp: resp, err: err}
+		}(rawURL, reader)
+	}
+
+	var lastErr error
+	for range urls {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if r.resp.IsSuccess() {
+			return r.resp, nil
+		}
+		lastErr = fmt.Errorf("httpclient: unexpected status %d", r.resp.StatusCode)
+	}
+
+	return nil, lastErr
+}
+
+// send performs a single request and reads the whole response body.
+func (c *Client) send(req *http.Request) (*Response, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+	if err := c.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	if c.onRequest != nil {
+		c.onRequest(req)
+	}
+	start := time.Now()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if c.onResponse != nil {
+		c.onResponse(resp, time.Since(start))
+	}
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       respBody,
+
+		checkContentType: c.checkContentType,
+	}, nil
 }
@@ -0,0 +1,659 @@
+package httpclient_test
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+// newEchoServer answers every request with its method, path and body, and
+// checks that the client's default header was sent.
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "verbs" {
+			t.Errorf("X-Test header = %q, want %q", r.Header.Get("X-Test"), "verbs")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(body)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerbs(t *testing.T) {
+	server := newEchoServer(t)
+	client := httpclient.New(httpclient.Config{
+		BaseURL: server.URL,
+		Headers: map[string]string{"X-Test": "verbs"},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		send func() (*httpclient.Response, error)
+		want string
+	}{
+		{"Get", func() (*httpclient.Response, error) { return client.Get(ctx, "/users") }, "GET /users "},
+		{"Post", func() (*httpclient.Response, error) { return client.Post(ctx, "/users", []byte("new")) }, "POST /users new"},
+		{"Put", func() (*httpclient.Response, error) { return client.Put(ctx, "/users/1", []byte("replace")) }, "PUT /users/1 replace"},
+		{"Patch", func() (*httpclient.Response, error) { return client.Patch(ctx, "/users/1", []byte("edit")) }, "PATCH /users/1 edit"},
+		{"Delete", func() (*httpclient.Response, error) { return client.Delete(ctx, "/users/1") }, "DELETE /users/1 "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.send()
+			if err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusCreated)
+			}
+			if got := resp.Header.Get("X-Method"); got != strings.ToUpper(tt.name) {
+				t.Errorf("X-Method header = %q, want %q", got, strings.ToUpper(tt.name))
+			}
+			if string(resp.Body) != tt.want {
+				t.Errorf("Body = %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWithParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL})
+
+	params := url.Values{}
+	params.Set("q", "a&b c")
+	params.Add("tag", "go")
+	params.Add("tag", "http")
+
+	resp, err := client.GetWithParams(context.Background(), "/search?page=2", params)
+	if err != nil {
+		t.Fatalf("GetWithParams() error = %v", err)
+	}
+
+	want := "page=2&q=a%26b+c&tag=go&tag=http"
+	if string(resp.Body) != want {
+		t.Errorf("query = %q, want %q", resp.Body, want)
+	}
+}
+
+func TestDoAppliesDefaultHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Custom"); got != "hand-built" {
+			t.Errorf("X-Custom header = %q, want %q", got, "hand-built")
+		}
+		if got := r.Header.Get("X-Default"); got != "client" {
+			t.Errorf("X-Default header = %q, want %q", got, "client")
+		}
+		// The request's own value wins over the client default
+		if got := r.Header.Get("X-Override"); got != "request" {
+			t.Errorf("X-Override header = %q, want %q", got, "request")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + " " + string(body)))
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL: server.URL,
+		Headers: map[string]string{"X-Default": "client", "X-Override": "client"},
+	})
+
+	for _, target := range []string{"/items", server.URL + "/items"} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, target, strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		req.Header.Set("X-Custom", "hand-built")
+		req.Header.Set("X-Override", "request")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", target, err)
+		}
+		if string(resp.Body) != "/items payload" {
+			t.Errorf("Do(%s) Body = %q, want %q", target, resp.Body, "/items payload")
+		}
+	}
+}
+
+func TestDoReusedRequest(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("Bearer token-2")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != valid.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body is not gzip: %v", err)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	refreshes := 0
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL, Compress: true},
+		httpclient.WithTokenRefresh(func() (string, error) {
+			refreshes++
+			return "token-" + strconv.Itoa(refreshes), nil
+		}))
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "/items", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for i, token := range []string{"Bearer token-2", "Bearer token-3"} {
+		// The server moves on to a new token before the request is sent again
+		valid.Store(token)
+		req.Body = io.NopCloser(strings.NewReader("payload"))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() %d error = %v", i+1, err)
+		}
+		if resp.StatusCode != http.StatusOK || string(resp.Body) != "payload" {
+			t.Errorf("Do() %d = %v %q, want %v %q", i+1, resp.StatusCode, resp.Body, http.StatusOK, "payload")
+		}
+	}
+	if len(req.Header) != 0 {
+		t.Errorf("caller's request headers = %v, want them left empty", req.Header)
+	}
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:    server.URL,
+		RetryCount: 2,
+	}, httpclient.WithRetryBackoff(time.Millisecond))
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("server hits = %d, want 3", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	// newThrottlingServer answers 429 with retryAfter once, then 200
+	newThrottlingServer := func(retryAfter string) *httptest.Server {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.Header().Set("Retry-After", retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("seconds", func(t *testing.T) {
+		server := newThrottlingServer("1")
+		client := httpclient.New(httpclient.Config{
+			BaseURL:    server.URL,
+			RetryCount: 1,
+		}, httpclient.WithRetryBackoff(time.Millisecond))
+
+		start := time.Now()
+		resp, err := client.Get(context.Background(), "/")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond || elapsed > 3*time.Second {
+			t.Errorf("Get() took %v, want about the 1s the server asked for", elapsed)
+		}
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		server := newThrottlingServer("3600")
+		client := httpclient.New(httpclient.Config{
+			BaseURL:    server.URL,
+			RetryCount: 1,
+		}, httpclient.WithMaxRetryAfter(50*time.Millisecond))
+
+		start := time.Now()
+		resp, err := client.Get(context.Background(), "/")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Get() took %v, want the wait capped at 50ms", elapsed)
+		}
+	})
+}
+
+func TestRetrySkipsNonIdempotent(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:    server.URL,
+		RetryCount: 3,
+	}, httpclient.WithRetryBackoff(time.Millisecond))
+
+	resp, err := client.Post(context.Background(), "/", []byte("once"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusInternalServerError)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hits = %d, want 1 for a POST", n)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:    server.URL,
+		RetryCount: 3,
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := client.Get(ctx, "/slow")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Get() error = %v, want %v", err, context.Canceled)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Get() returned after %v, want it to stop promptly", elapsed)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.Get(ctx, "/slow")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Get() returned after %v, want it to stop at the deadline", elapsed)
+		}
+	})
+}
+
+func TestPerRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL: server.URL,
+		Timeout: 10 * time.Second,
+	})
+
+	start := time.Now()
+	_, err := client.Get(context.Background(), "/slow", httpclient.WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get() returned after %v, want the per-request timeout to fire first", elapsed)
+	}
+}
+
+// newRedirectServer redirects /redirect/n to /redirect/n-1 until /redirect/0,
+// which answers "done".
+func newRedirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if n > 0 {
+			http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDisableRedirects(t *testing.T) {
+	server := newRedirectServer(t)
+	client := httpclient.New(httpclient.Config{
+		BaseURL:          server.URL,
+		DisableRedirects: true,
+	})
+
+	resp, err := client.Get(context.Background(), "/redirect/1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusFound)
+	}
+	if got := resp.Header.Get("Location"); got != "/redirect/0" {
+		t.Errorf("Location = %q, want %q", got, "/redirect/0")
+	}
+}
+
+func TestMaxRedirects(t *testing.T) {
+	server := newRedirectServer(t)
+	client := httpclient.New(httpclient.Config{
+		BaseURL:      server.URL,
+		MaxRedirects: 2,
+	})
+
+	resp, err := client.Get(context.Background(), "/redirect/2")
+	if err != nil {
+		t.Fatalf("Get() within the limit error = %v", err)
+	}
+	if string(resp.Body) != "done" {
+		t.Errorf("Body = %q, want %q", resp.Body, "done")
+	}
+
+	if _, err := client.Get(context.Background(), "/redirect/3"); !errors.Is(err, httpclient.ErrTooManyRedirects) {
+		t.Errorf("Get() past the limit error = %v, want %v", err, httpclient.ErrTooManyRedirects)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/found" {
+			w.Write([]byte("here"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no such user"))
+	}))
+	defer server.Close()
+
+	t.Run("disabled", func(t *testing.T) {
+		client := httpclient.New(httpclient.Config{BaseURL: server.URL})
+
+		resp, err := client.Get(context.Background(), "/users/42")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if resp.IsSuccess() {
+			t.Errorf("IsSuccess() = true for %v", resp.StatusCode)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		client := httpclient.New(httpclient.Config{
+			BaseURL:          server.URL,
+			ErrorOnHTTPError: true,
+		})
+
+		_, err := client.Get(context.Background(), "/users/42")
+		var httpErr *httpclient.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("Get() error = %v, want *HTTPError", err)
+		}
+		if httpErr.StatusCode != http.StatusNotFound || httpErr.Status != "404 Not Found" {
+			t.Errorf("HTTPError status = %d %q, want 404 %q", httpErr.StatusCode, httpErr.Status, "404 Not Found")
+		}
+		if string(httpErr.Body) != "no such user" {
+			t.Errorf("HTTPError.Body = %q, want %q", httpErr.Body, "no such user")
+		}
+
+		resp, err := client.Get(context.Background(), "/found")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if !resp.IsSuccess() {
+			t.Errorf("IsSuccess() = false for %v", resp.StatusCode)
+		}
+	})
+}
+
+func TestNewWithError(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     httpclient.Config
+		opts    []httpclient.Option
+		wantErr bool
+	}{
+		{"valid", httpclient.Config{BaseURL: "https://api.example.com/v1", RetryCount: 2, Timeout: time.Second}, nil, false},
+		{"empty", httpclient.Config{}, nil, false},
+		{"malformed URL", httpclient.Config{BaseURL: "http://[::1"}, nil, true},
+		{"no scheme", httpclient.Config{BaseURL: "api.example.com"}, nil, true},
+		{"unknown scheme", httpclient.Config{BaseURL: "ftp://files.example.com"}, nil, true},
+		{"negative retry count", httpclient.Config{BaseURL: "https://api.example.com", RetryCount: -1}, nil, true},
+		{"negative timeout", httpclient.Config{Timeout: -time.Second}, nil, true},
+		{"invalid proxy", httpclient.Config{Proxy: "not a proxy"}, nil, true},
+		{"invalid option base URL", httpclient.Config{}, []httpclient.Option{httpclient.WithBaseURLs("https://a.example.com", "b.example.com")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := httpclient.NewWithError(tt.cfg, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWithError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && client == nil {
+				t.Errorf("NewWithError() returned a nil client without an error")
+			}
+		})
+	}
+}
+
+func TestBaseURLFailover(t *testing.T) {
+	// The first base URL points at a server that is no longer listening
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "failover" {
+			t.Errorf("X-Test header = %q, want %q", r.Header.Get("X-Test"), "failover")
+		}
+		if r.URL.Path != "/users" {
+			t.Errorf("Path = %q, want %q", r.URL.Path, "/users")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer up.Close()
+
+	client := httpclient.New(httpclient.Config{
+		Headers: map[string]string{"X-Test": "failover"},
+	}, httpclient.WithBaseURLs(downURL, up.URL))
+
+	resp, err := client.Get(context.Background(), "/users")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if string(resp.Body) != "ok" {
+		t.Errorf("Body = %q, want %q", resp.Body, "ok")
+	}
+	if client.CurrentBaseURL() != up.URL {
+		t.Errorf("CurrentBaseURL() = %v, want %v", client.CurrentBaseURL(), up.URL)
+	}
+}
+
+func TestBaseURLFailoverOn5xx(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	client := httpclient.New(httpclient.Config{}, httpclient.WithBaseURLs(failing.URL, healthy.URL))
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if client.CurrentBaseURL() != healthy.URL {
+		t.Errorf("CurrentBaseURL() = %v, want %v", client.CurrentBaseURL(), healthy.URL)
+	}
+}
+
+func TestBaseURLFailoverOn5xxSkipsNonIdempotent(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	var healthyHits atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	client := httpclient.New(httpclient.Config{}, httpclient.WithBaseURLs(failing.URL, healthy.URL))
+
+	// The first server may have applied the POST before failing
+	resp, err := client.Post(context.Background(), "/", []byte("once"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusInternalServerError)
+	}
+	if n := healthyHits.Load(); n != 0 {
+		t.Errorf("second base URL got %d POSTs, want 0", n)
+	}
+
+	client = httpclient.New(httpclient.Config{}, httpclient.WithBaseURLs(failing.URL, healthy.URL),
+		httpclient.WithRetryNonIdempotent(true))
+	resp, err = client.Post(context.Background(), "/", []byte("again"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v with WithRetryNonIdempotent, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestRaceFastestWins(t *testing.T) {
+	slowCancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Consume the body so the server notices when the client goes away
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(slowCancelled)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("slow"))
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "ping" {
+			t.Errorf("Body = %q, want %q", body, "ping")
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	client := httpclient.New(httpclient.Config{})
+
+	resp, err := client.Race(context.Background(), http.MethodPost, []string{slow.URL, fast.URL}, func() io.Reader {
+		return strings.NewReader("ping")
+	})
+	if err != nil {
+		t.Fatalf("Race() error = %v", err)
+	}
+	if string(resp.Body) != "fast" {
+		t.Errorf("Body = %q, want %q", resp.Body, "fast")
+	}
+
+	select {
+	case <-slowCancelled:
+	case <-time.After(2 * time.Second):
+		t.Errorf("slow request was not cancelled")
+	}
+}
+
+func TestRaceAllFail(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	client := httpclient.New(httpclient.Config{})
+
+	if _, err := client.Race(context.Background(), http.MethodGet, []string{failing.URL, failing.URL}, nil); err == nil {
+		t.Errorf("Race() expected error when no endpoint succeeds")
+	}
+}
@@ -0,0 +1,69 @@
+package httpclient_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestGzipResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"name":"compressed"}`))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	// Asking for gzip explicitly stops the transport from decoding it
+	client := httpclient.New(httpclient.Config{
+		BaseURL: server.URL,
+		Headers: map[string]string{"Accept-Encoding": "gzip"},
+	})
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(resp.Body) != `{"name":"compressed"}` {
+		t.Errorf("Body = %q, want the decompressed JSON", resp.Body)
+	}
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q after decoding, want none", got)
+	}
+}
+
+func TestGzipRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding = %q, want %q", got, "gzip")
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body is not gzip: %v", err)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:  server.URL,
+		Compress: true,
+	})
+
+	resp, err := client.Post(context.Background(), "/", []byte(`{"large":"payload"}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if string(resp.Body) != `{"large":"payload"}` {
+		t.Errorf("server decoded %q, want %q", resp.Body, `{"large":"payload"}`)
+	}
+}
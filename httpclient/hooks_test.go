@@ -0,0 +1,48 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var requested string
+	var status int
+	var took time.Duration
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL: server.URL,
+		OnRequest: func(req *http.Request) {
+			requested = req.Method + " " + req.URL.Path
+		},
+		OnResponse: func(resp *http.Response, d time.Duration) {
+			status = resp.StatusCode
+			took = d
+		},
+	})
+
+	if _, err := client.Get(context.Background(), "/jobs"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if requested != "GET /jobs" {
+		t.Errorf("OnRequest saw %q, want %q", requested, "GET /jobs")
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("OnResponse status = %v, want %v", status, http.StatusAccepted)
+	}
+	if took < 20*time.Millisecond || took > 2*time.Second {
+		t.Errorf("OnResponse duration = %v, want about the server's 20ms", took)
+	}
+}
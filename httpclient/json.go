@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// snippetSize caps how much of an unexpected body is kept in errors.
+const snippetSize = 128
+
+// ErrUnexpectedContentType is matched by errors.Is when a response that should
+// be JSON carries a different Content-Type.
+var ErrUnexpectedContentType = errors.New("httpclient: unexpected content type")
+
+// ContentTypeError reports the actual Content-Type and the start of the body
+// of a response that was expected to be JSON.
+type ContentTypeError struct {
+	ContentType string
+	Snippet     string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("httpclient: expected JSON response, got content type %q: %q", e.ContentType, e.Snippet)
+}
+
+func (e *ContentTypeError) Unwrap() error {
+	return ErrUnexpectedContentType
+}
+
+// WithContentTypeCheck controls whether JSON decoding first verifies that the
+// response Content-Type is JSON. It is enabled by default.
+func WithContentTypeCheck(enabled bool) Option {
+	return func(c *Client) {
+		c.checkContentType = enabled
+	}
+}
+
+// JSON decodes the response body into v. When the client checks content
+// types, a non-JSON Content-Type yields a *ContentTypeError instead.
+func (r *Response) JSON(v any) error {
+	if r.checkContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		snippet := r.Body
+		if len(snippet) > snippetSize {
+			snippet = snippet[:snippetSize]
+		}
+		return &ContentTypeError{
+			ContentType: r.Header.Get("Content-Type"),
+			Snippet:     string(snippet),
+		}
+	}
+
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return nil
+}
+
+// GetJSON sends a GET request to path and decodes the JSON response into v.
+func (c *Client) GetJSON(ctx context.Context, path string, v any, opts ...RequestOption) error {
+	resp, err := c.Get(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	return resp.JSON(v)
+}
+
+// isJSONContentType accepts application/json and any +json media type.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
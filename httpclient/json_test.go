@@ -0,0 +1,79 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestGetJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"name":"gopher"}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL})
+
+	var got struct {
+		Name string `json:"name"`
+	}
+	if err := client.GetJSON(context.Background(), "/", &got); err != nil {
+		t.Fatalf("GetJSON() error = %v", err)
+	}
+	if got.Name != "gopher" {
+		t.Errorf("Name = %q, want %q", got.Name, "gopher")
+	}
+}
+
+func TestGetJSONUnexpectedContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Oops</body></html>"))
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL})
+
+	var got map[string]any
+	err := client.GetJSON(context.Background(), "/", &got)
+	if !errors.Is(err, httpclient.ErrUnexpectedContentType) {
+		t.Fatalf("GetJSON() error = %v, want ErrUnexpectedContentType", err)
+	}
+
+	var ctErr *httpclient.ContentTypeError
+	if !errors.As(err, &ctErr) {
+		t.Fatalf("GetJSON() error = %T, want *ContentTypeError", err)
+	}
+	if ctErr.ContentType != "text/html" {
+		t.Errorf("ContentType = %q, want %q", ctErr.ContentType, "text/html")
+	}
+	if !strings.Contains(ctErr.Snippet, "Oops") {
+		t.Errorf("Snippet = %q, want it to contain the body", ctErr.Snippet)
+	}
+}
+
+func TestGetJSONWithoutContentTypeCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL}, httpclient.WithContentTypeCheck(false))
+
+	var got struct {
+		OK bool `json:"ok"`
+	}
+	if err := client.GetJSON(context.Background(), "/", &got); err != nil {
+		t.Fatalf("GetJSON() error = %v", err)
+	}
+	if !got.OK {
+		t.Errorf("OK = false, want true")
+	}
+}
@@ -0,0 +1,31 @@
+package httpclient
+
+import "net/http"
+
+// RoundTripFunc sends a single HTTP request and returns its response. It
+// implements http.RoundTripper.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the round trip of every request the client sends, for
+// logging, tracing or header injection. It may change the request before
+// calling next and inspect or change the response after.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// chain wraps transport in middlewares, the first one outermost, so it sees
+// the request first and the response last.
+func chain(transport http.RoundTripper, middlewares []Middleware) http.RoundTripper {
+	if len(middlewares) == 0 {
+		return transport
+	}
+
+	next := RoundTripFunc(transport.RoundTrip)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next
+}
@@ -0,0 +1,60 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestMiddlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Trace"); got != "abc" {
+			t.Errorf("X-Trace header = %q, want %q", got, "abc")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var calls []string
+	var status int
+
+	injectHeader := func(next httpclient.RoundTripFunc) httpclient.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "inject")
+			req.Header.Set("X-Trace", "abc")
+			return next(req)
+		}
+	}
+	recordStatus := func(next httpclient.RoundTripFunc) httpclient.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "record")
+			if req.Header.Get("X-Trace") == "" {
+				t.Errorf("inner middleware ran before the outer one set X-Trace")
+			}
+			resp, err := next(req)
+			if err == nil {
+				status = resp.StatusCode
+			}
+			return resp, err
+		}
+	}
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:     server.URL,
+		Middlewares: []httpclient.Middleware{injectHeader, recordStatus},
+	})
+
+	if _, err := client.Get(context.Background(), "/"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "inject" || calls[1] != "record" {
+		t.Errorf("middleware calls = %v, want [inject record]", calls)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("recorded status = %v, want %v", status, http.StatusAccepted)
+	}
+}
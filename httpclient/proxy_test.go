@@ -0,0 +1,42 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestProxy(t *testing.T) {
+	// A forward proxy receives the absolute target URL in the request line
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL: "http://api.example.invalid",
+		Proxy:   proxy.URL,
+	})
+
+	resp, err := client.Get(context.Background(), "/users")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if want := "proxied http://api.example.invalid/users"; string(resp.Body) != want {
+		t.Errorf("Body = %q, want %q", resp.Body, want)
+	}
+}
+
+func TestProxyInvalidURL(t *testing.T) {
+	client := httpclient.New(httpclient.Config{
+		BaseURL: "http://api.example.invalid",
+		Proxy:   "not a proxy",
+	})
+
+	if _, err := client.Get(context.Background(), "/users"); err == nil {
+		t.Errorf("Get() expected error for an invalid proxy URL")
+	}
+}
@@ -0,0 +1,38 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{
+		BaseURL:   server.URL,
+		RateLimit: 20,
+		RateBurst: 2,
+	})
+
+	// The first two requests use the burst, the other four wait 50ms each
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := client.Get(context.Background(), "/"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 180*time.Millisecond {
+		t.Errorf("6 requests at 20/s with burst 2 took %v, want at least 200ms", elapsed)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("6 requests at 20/s with burst 2 took %v, want about 200ms", elapsed)
+	}
+}
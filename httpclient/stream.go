@@ -0,0 +1,58 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Stream sends a request to path relative to the current base URL and
+// returns the response body unread, for downloads and long-lived responses
+// such as server-sent events. The caller must close the body. Nothing is
+// retried, and the client Timeout does not apply since it would cut the
+// stream short; bound the call with ctx instead. OnResponse is called once
+// the response headers arrive.
+func (c *Client) Stream(ctx context.Context, method, path string, body io.Reader) (io.ReadCloser, *http.Response, error) {
+	rawURL, err := joinURL(c.CurrentBaseURL(), path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.applyHeaders(req)
+	if req.Header.Get("Authorization") == "" {
+		if err := c.authorize(req, false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.configErr != nil {
+		return nil, nil, c.configErr
+	}
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	if c.onRequest != nil {
+		c.onRequest(req)
+	}
+	start := time.Now()
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.onResponse != nil {
+		c.onResponse(resp, time.Since(start))
+	}
+	return resp.Body, resp, nil
+}
@@ -0,0 +1,57 @@
+package httpclient_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kazukodevv/httpclient"
+)
+
+func TestStream(t *testing.T) {
+	next := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "event %d\n", i)
+			w.(http.Flusher).Flush()
+			// Hold the next chunk back until the client has read this one
+			select {
+			case <-next:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.New(httpclient.Config{BaseURL: server.URL})
+
+	body, resp, err := client.Stream(context.Background(), http.MethodGet, "/events", nil)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	defer body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("TransferEncoding = %v, want [chunked]", resp.TransferEncoding)
+	}
+
+	reader := bufio.NewReader(body)
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("event %d\n", i); line != want {
+			t.Errorf("event %d = %q, want %q", i, line, want)
+		}
+		next <- struct{}{}
+	}
+}
@@ -0,0 +1,40 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsConfig builds the TLS configuration for cfg: a copy of cfg.TLSConfig, or
+// a fresh one requiring TLS 1.2, with the CA and client certificate files
+// loaded on top.
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSConfig != nil {
+		config = cfg.TLSConfig.Clone()
+	}
+
+	if cfg.CACertPath != "" {
+		pemData, err := os.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: failed to read CA certificates: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("httpclient: no PEM certificates found in %s", cfg.CACertPath)
+		}
+		config.RootCAs = pool
+	}
+
+	if cfg.ClientCertPath != "" || cfg.ClientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: failed to load client certificate: %w", err)
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTLSTestServer(t *testing.T, minVersion, maxVersion uint16) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		MinVersion: minVersion,
+		MaxVersion: maxVersion,
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // Handshake failures are expected
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// trustTestServer makes the client trust the test server's certificate.
+func trustTestServer(c *Client, server *httptest.Server) {
+	c.transport.TLSClientConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+}
+
+func TestDefaultRejectsTLS11(t *testing.T) {
+	server := newTLSTestServer(t, tls.VersionTLS10, tls.VersionTLS11)
+
+	client := New(Config{BaseURL: server.URL})
+	trustTestServer(client, server)
+
+	if _, err := client.Get(context.Background(), "/"); err == nil {
+		t.Fatalf("Get() against a TLS 1.1 server should fail the handshake")
+	}
+}
+
+func TestDefaultAcceptsTLS12(t *testing.T) {
+	server := newTLSTestServer(t, tls.VersionTLS12, tls.VersionTLS12)
+
+	client := New(Config{BaseURL: server.URL})
+	trustTestServer(client, server)
+
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestWithMinTLSVersion(t *testing.T) {
+	server := newTLSTestServer(t, tls.VersionTLS12, tls.VersionTLS12)
+
+	client := New(Config{BaseURL: server.URL}, WithMinTLSVersion(tls.VersionTLS13))
+	trustTestServer(client, server)
+
+	if _, err := client.Get(context.Background(), "/"); err == nil {
+		t.Fatalf("Get() against a TLS 1.2 server should fail when TLS 1.3 is required")
+	}
+}
+
+func TestWithCipherSuites(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // Handshake failures are expected
+	server.StartTLS()
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL},
+		WithCipherSuites(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))
+	trustTestServer(client, server)
+
+	if _, err := client.Get(context.Background(), "/"); err == nil {
+		t.Fatalf("Get() should fail when no allowed cipher suite is shared")
+	}
+}
+
+// writeServerCA writes the test server's certificate to a PEM file.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, pemData, 0o600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	return path
+}
+
+func TestCACertPath(t *testing.T) {
+	server := newTLSTestServer(t, tls.VersionTLS12, tls.VersionTLS13)
+
+	client := New(Config{BaseURL: server.URL, CACertPath: writeServerCA(t, server)})
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Get() with the server CA error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	// Without the CA the server's certificate is not trusted
+	if _, err := New(Config{BaseURL: server.URL}).Get(context.Background(), "/"); err == nil {
+		t.Errorf("Get() without the server CA should fail verification")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := newTLSTestServer(t, tls.VersionTLS12, tls.VersionTLS13)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client := New(Config{BaseURL: server.URL, TLSConfig: &tls.Config{RootCAs: pool}})
+
+	if _, err := client.Get(context.Background(), "/"); err != nil {
+		t.Fatalf("Get() with a provided CA pool error = %v", err)
+	}
+}
+
+func TestTLSFilesInvalid(t *testing.T) {
+	client := New(Config{
+		BaseURL:        "https://localhost",
+		ClientCertPath: filepath.Join(t.TempDir(), "missing.pem"),
+		ClientKeyPath:  filepath.Join(t.TempDir(), "missing-key.pem"),
+	})
+
+	if _, err := client.Get(context.Background(), "/"); err == nil {
+		t.Errorf("Get() expected error for a missing client certificate")
+	}
+}
@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTransportConfig(t *testing.T) {
+	c := New(Config{
+		Transport: TransportConfig{
+			MaxIdleConns:        200,
+			MaxIdleConnsPerHost: 50,
+			IdleConnTimeout:     30 * time.Second,
+			DisableKeepAlives:   true,
+		},
+	})
+
+	if c.transport.MaxIdleConns != 200 {
+		t.Errorf("MaxIdleConns = %v, want %v", c.transport.MaxIdleConns, 200)
+	}
+	if c.transport.MaxIdleConnsPerHost != 50 {
+		t.Errorf("MaxIdleConnsPerHost = %v, want %v", c.transport.MaxIdleConnsPerHost, 50)
+	}
+	if c.transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want %v", c.transport.IdleConnTimeout, 30*time.Second)
+	}
+	if !c.transport.DisableKeepAlives {
+		t.Errorf("DisableKeepAlives = false, want true")
+	}
+}
+
+func TestTransportConfigDefaults(t *testing.T) {
+	c := New(Config{})
+	def := http.DefaultTransport.(*http.Transport)
+
+	if c.transport.MaxIdleConns != def.MaxIdleConns {
+		t.Errorf("MaxIdleConns = %v, want default %v", c.transport.MaxIdleConns, def.MaxIdleConns)
+	}
+	if c.transport.MaxIdleConnsPerHost != def.MaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %v, want default %v", c.transport.MaxIdleConnsPerHost, def.MaxIdleConnsPerHost)
+	}
+	if c.transport.IdleConnTimeout != def.IdleConnTimeout {
+		t.Errorf("IdleConnTimeout = %v, want default %v", c.transport.IdleConnTimeout, def.IdleConnTimeout)
+	}
+	if c.transport.DisableKeepAlives {
+		t.Errorf("DisableKeepAlives = true, want false")
+	}
+}
@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// joinURL resolves path against base. A relative path is appended to the
+// base path whatever slashes either side has, so "https://host/api" and
+// "users" give "https://host/api/users". An absolute http or https URL
+// replaces base entirely. The query on path is kept.
+func joinURL(base, path string) (string, error) {
+	if path == "" {
+		return base, nil
+	}
+
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid request path %q: %w", path, err)
+	}
+	if ref.Scheme == "http" || ref.Scheme == "https" {
+		return ref.String(), nil
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+
+	// Resolve against the base as a directory so its last segment is kept
+	if !strings.HasSuffix(baseURL.Path, "/") {
+		baseURL.Path += "/"
+		if baseURL.RawPath != "" {
+			baseURL.RawPath += "/"
+		}
+	}
+	ref.Path = strings.TrimLeft(ref.Path, "/")
+	ref.RawPath = strings.TrimLeft(ref.RawPath, "/")
+
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+// withQuery adds params to the query of path, keeping any query path already
+// has. Values are escaped and repeated keys are sent once per value.
+func withQuery(path string, params url.Values) (string, error) {
+	if len(params) == 0 {
+		return path, nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid request path %q: %w", path, err)
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,40 @@
+package httpclient
+
+import "testing"
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		path string
+		want string
+	}{
+		{"no slashes", "https://host.com", "users", "https://host.com/users"},
+		{"base slash", "https://host.com/", "users", "https://host.com/users"},
+		{"path slash", "https://host.com", "/users", "https://host.com/users"},
+		{"both slashes", "https://host.com/", "/users", "https://host.com/users"},
+		{"base path", "https://host.com/api", "users/1", "https://host.com/api/users/1"},
+		{"base path with slashes", "https://host.com/api/", "/users/1", "https://host.com/api/users/1"},
+		{"query", "https://host.com/api", "/users?page=2&sort=name", "https://host.com/api/users?page=2&sort=name"},
+		{"empty path", "https://host.com/api", "", "https://host.com/api"},
+		{"absolute override", "https://host.com/api", "http://other.com/health?full=1", "http://other.com/health?full=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := joinURL(tt.base, tt.path)
+			if err != nil {
+				t.Fatalf("joinURL(%q, %q) error = %v", tt.base, tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("joinURL(%q, %q) = %q, want %q", tt.base, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinURLInvalid(t *testing.T) {
+	if _, err := joinURL("https://host.com", "%zz"); err == nil {
+		t.Errorf("joinURL() expected error for an invalid path")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func main() {
+	server := flag.String("server", fmt.Sprintf("127.0.0.1:%d", dns.DNS_PORT), "DNS server to query, as host:port")
+	qtypeName := flag.String("type", "A", "record type to query: A, AAAA, CNAME, MX, TXT, ...")
+	timeout := flag.Duration("timeout", dns.DEFAULT_RESOLVER_TIMEOUT, "time to wait for each response")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] name\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+
+	qtype, ok := dns.ParseTypeName(*qtypeName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown record type %q\n", *qtypeName)
+		os.Exit(2)
+	}
+
+	resolver := dns.NewResolver(*server, dns.WithResolverTimeout(*timeout))
+
+	start := time.Now()
+	response, err := resolver.Query(name, qtype)
+	latency := time.Since(start)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf(";; QUESTION: %s %s\n", name, dns.TypeName(qtype))
+	dns.FormatResponse(os.Stdout, response, *server, latency)
+}
@@ -1,29 +1,48 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"dns-server/internal/dns"
+	"dns-server/internal/logging"
 )
 
 func main() {
+	recordsFile := flag.String("records", "", "JSON records file to serve; re-read on SIGHUP")
+	forward := flag.String("forward", "", "upstream DNS server for names without local records")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", logging.FORMAT_JSON, "log format: json or text")
+	flag.Parse()
+
 	// Initialize structured logger
-	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level:     slog.LevelDebug,
-		AddSource: true,
-	})
+	handler, err := logging.NewHandler(os.Stdout, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
 	// Create and start DNS server
-	server := dns.NewServer(dns.DNS_PORT, logger)
+	var opts []dns.ServerOption
+	if *forward != "" {
+		opts = append(opts, dns.WithForwarder(*forward))
+	}
+	server := dns.NewServer(dns.DNS_PORT, logger, opts...)
+	if *recordsFile != "" {
+		if err := server.ReloadRecords(*recordsFile); err != nil {
+			os.Exit(1)
+		}
+	}
 
-	// Set up signal handling for graceful shutdown
+	// Set up signal handling for graceful shutdown and record reloads
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Start server in a goroutine
 	go func() {
@@ -33,8 +52,18 @@ func main() {
 		}
 	}()
 
-	// Wait for shutdown signal
-	<-sigChan
+	// Wait for shutdown signal, reloading records on SIGHUP
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if *recordsFile == "" {
+			logger.Warn("Ignoring SIGHUP: no records file configured")
+			continue
+		}
+		// A failed reload is logged and the current records keep serving
+		_ = server.ReloadRecords(*recordsFile)
+	}
 	logger.Info("Shutting down DNS server...")
 
 	if err := server.Stop(); err != nil {
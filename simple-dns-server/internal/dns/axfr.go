@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+)
+
+// handleAXFR answers a zone transfer request (RFC 5936) by streaming the
+// zone's SOA, every record in the zone and the SOA again. Transfers are only
+// served over TCP, for the zone configured with WithSOA, to clients inside
+// the networks given to WithAllowedNetworks; everyone else is refused. It
+// returns the RCODE of the response.
+func (s *Server) handleAXFR(query *DNSMessage, clientAddr net.Addr, via transport, write func([]byte) error, logger *slog.Logger) uint8 {
+	question := query.Questions[0]
+	zone := strings.ToLower(strings.TrimSuffix(question.Name, "."))
+
+	refuse := func(reason string) uint8 {
+		logger.Warn("Refusing zone transfer", "zone", zone, "reason", reason)
+		response := &DNSMessage{
+			Header:    DNSHeader{ID: query.Header.ID, QDCount: 1},
+			Questions: query.Questions,
+		}
+		response.Header.SetQR(true)
+		response.Header.SetRCODE(RCODE_REFUSED)
+		if err := write(s.encodeResponse(response, logger)); err != nil {
+			logger.Error("Failed to send DNS response", "error", err)
+		}
+		return RCODE_REFUSED
+	}
+
+	switch {
+	case via != transportTCP:
+		return refuse("zone transfers require TCP")
+	case len(s.allowedNetworks) == 0:
+		return refuse("no allowed networks configured")
+	case s.soa == nil || zone != s.soaZone:
+		return refuse("not authoritative for zone")
+	}
+
+	soa, err := s.soaRecord()
+	if err != nil {
+		logger.Error("Failed to encode SOA record", "error", err)
+		return refuse("invalid SOA")
+	}
+	soa.Name = question.Name
+
+	// Stored SOA records are left out, since an SOA marks the end of the transfer
+	zoneRecords := slices.DeleteFunc(s.records().ZoneRecords(zone), func(record DNSResourceRecord) bool {
+		return record.Type == TYPE_SOA
+	})
+	records := append([]DNSResourceRecord{soa}, zoneRecords...)
+	records = append(records, soa)
+
+	// Every record must fit a message on its own, even the first one that
+	// also repeats the question
+	questionSize := encodedSize(&DNSMessage{Questions: query.Questions}) - MIN_MESSAGE_SIZE
+	for _, record := range records {
+		if MIN_MESSAGE_SIZE+questionSize+recordSize(record) > MAX_TCP_MESSAGE_SIZE {
+			return refuse("record " + record.Name + " too large for a TCP message")
+		}
+	}
+
+	// Records are batched into messages of roughly AXFR_MESSAGE_SIZE bytes,
+	// never more than MAX_TCP_MESSAGE_SIZE; only the first message repeats
+	// the question
+	sent := 0
+	for len(records) > 0 {
+		response := &DNSMessage{Header: DNSHeader{ID: query.Header.ID}}
+		response.Header.SetQR(true)
+		response.Header.SetAA(true)
+		if sent == 0 {
+			response.Questions = query.Questions
+			response.Header.QDCount = 1
+		}
+
+		size := encodedSize(response)
+		for len(records) > 0 {
+			next := recordSize(records[0])
+			if len(response.Answers) > 0 && (size >= AXFR_MESSAGE_SIZE || size+next > MAX_TCP_MESSAGE_SIZE) {
+				break
+			}
+			size += next
+			response.Answers = append(response.Answers, records[0])
+			records = records[1:]
+		}
+		response.Header.ANCount = uint16(len(response.Answers))
+
+		if err := write(s.encodeResponse(response, logger)); err != nil {
+			logger.Error("Failed to send zone transfer", "error", err)
+			return RCODE_NOERROR
+		}
+		sent += len(response.Answers)
+	}
+
+	logger.Info("Zone transfer sent", "zone", zone, "record_count", sent)
+	return RCODE_NOERROR
+}
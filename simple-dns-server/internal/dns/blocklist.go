@@ -0,0 +1,75 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Blocklist matches domain names that the server sinkholes. Entries are exact
+// names or wildcards like "*.ads.example.com", which match every subdomain of
+// ads.example.com but not the name itself.
+type Blocklist struct {
+	names    map[string]struct{}
+	suffixes []string // Wildcard entries without the leading "*", e.g. ".ads.example.com"
+}
+
+// NewBlocklist creates a blocklist from the given entries
+func NewBlocklist(entries ...string) *Blocklist {
+	b := &Blocklist{names: make(map[string]struct{})}
+	for _, entry := range entries {
+		b.Add(entry)
+	}
+	return b
+}
+
+// LoadBlocklist reads a blocklist file with one entry per line. Blank lines
+// and lines starting with # are ignored.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	b := NewBlocklist()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.Add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return b, nil
+}
+
+// Add adds an exact name or a "*." wildcard entry to the blocklist. Only a
+// leading "*." makes a wildcard, so matches always fall on a label boundary;
+// an entry like "*foo.com" is taken as an exact name.
+func (b *Blocklist) Add(entry string) {
+	entry = strings.ToLower(strings.TrimSuffix(entry, "."))
+	if parent, ok := strings.CutPrefix(entry, "*."); ok {
+		b.suffixes = append(b.suffixes, "."+parent)
+		return
+	}
+	b.names[entry] = struct{}{}
+}
+
+// Blocked reports whether name matches an entry of the blocklist
+func (b *Blocklist) Blocked(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if _, exists := b.names[name]; exists {
+		return true
+	}
+	for _, suffix := range b.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// NewQuery encodes a recursive query with a single IN question
+func NewQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	return EncodeDNSMessage(&DNSMessage{
+		Header: DNSHeader{
+			ID:      id,
+			Flags:   FLAG_RD, // Standard query with recursion desired
+			QDCount: 1,
+		},
+		Questions: []DNSQuestion{
+			{Name: name, Type: qtype, Class: CLASS_IN},
+		},
+	})
+}
+
+// ParseTypeName returns the record type for a mnemonic such as "A" or "mx".
+// It is the inverse of TypeName.
+func ParseTypeName(name string) (uint16, bool) {
+	for _, qtype := range []uint16{TYPE_A, TYPE_NS, TYPE_CNAME, TYPE_SOA, TYPE_PTR,
+		TYPE_MX, TYPE_TXT, TYPE_AAAA, TYPE_ANY} {
+		if strings.EqualFold(name, TypeName(qtype)) {
+			return qtype, true
+		}
+	}
+	return 0, false
+}
+
+// RcodeName returns the mnemonic for a response code, e.g. "NXDOMAIN"
+func RcodeName(rcode uint8) string {
+	switch rcode {
+	case RCODE_NOERROR:
+		return "NOERROR"
+	case RCODE_FORMERR:
+		return "FORMERR"
+	case RCODE_SERVFAIL:
+		return "SERVFAIL"
+	case RCODE_NXDOMAIN:
+		return "NXDOMAIN"
+	case RCODE_NOTIMP:
+		return "NOTIMP"
+	case RCODE_REFUSED:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// FormatRecord renders a resource record in zone file presentation format
+func FormatRecord(record DNSResourceRecord) string {
+	return fmt.Sprintf("%s.\t%d\tIN\t%s\t%s", strings.TrimSuffix(record.Name, "."),
+		record.TTL, TypeName(record.Type), formatRecordData(record.Type, record.Data))
+}
+
+// FormatResponse writes a dig-style summary of response: its status, each
+// section's records, the latency and the server that answered
+func FormatResponse(w io.Writer, response *DNSMessage, server string, latency time.Duration) {
+	fmt.Fprintf(w, ";; status: %s, id: %d\n", RcodeName(response.Header.RCODE()), response.Header.ID)
+	fmt.Fprintf(w, ";; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		len(response.Questions), len(response.Answers), len(response.Authority), len(response.Additional))
+
+	for _, section := range []struct {
+		name    string
+		records []DNSResourceRecord
+	}{
+		{"ANSWER", response.Answers},
+		{"AUTHORITY", response.Authority},
+		{"ADDITIONAL", response.Additional},
+	} {
+		if len(section.records) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n;; %s SECTION:\n", section.name)
+		for _, record := range section.records {
+			fmt.Fprintln(w, FormatRecord(record))
+		}
+	}
+
+	fmt.Fprintf(w, "\n;; Query time: %s\n", latency.Round(time.Microsecond))
+	fmt.Fprintf(w, ";; SERVER: %s\n", server)
+}
@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// parseCookieOption returns the data of the COOKIE option in OPT RDATA, or nil
+// when there is none. A cookie of invalid length is a format error.
+func parseCookieOption(rdata []byte) ([]byte, error) {
+	for len(rdata) >= 4 {
+		code := binary.BigEndian.Uint16(rdata[0:2])
+		length := int(binary.BigEndian.Uint16(rdata[2:4]))
+		if 4+length > len(rdata) {
+			return nil, fmt.Errorf("%w: EDNS option extends beyond OPT data", ErrUnexpectedEnd)
+		}
+		data := rdata[4 : 4+length]
+		rdata = rdata[4+length:]
+
+		if code != EDNS_OPTION_COOKIE {
+			continue
+		}
+		serverLength := length - CLIENT_COOKIE_SIZE
+		if length < CLIENT_COOKIE_SIZE || serverLength > 0 && (serverLength < MIN_SERVER_COOKIE || serverLength > MAX_SERVER_COOKIE) {
+			return nil, fmt.Errorf("invalid COOKIE option length: %d", length)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// EncodeCookieOption encodes cookie as a COOKIE option for OPT RDATA
+func EncodeCookieOption(cookie []byte) []byte {
+	option := make([]byte, 4, 4+len(cookie))
+	binary.BigEndian.PutUint16(option[0:2], EDNS_OPTION_COOKIE)
+	binary.BigEndian.PutUint16(option[2:4], uint16(len(cookie)))
+	return append(option, cookie...)
+}
+
+// serverCookie computes the server cookie for a client cookie and client
+// address: an HMAC of both under the server secret, so it cannot be forged
+// without the secret and is only valid from the address it was issued to.
+func (s *Server) serverCookie(clientCookie []byte, clientAddr net.Addr) []byte {
+	mac := hmac.New(sha256.New, s.cookieSecret)
+	mac.Write(clientCookie)
+	if ip, ok := clientIP(clientAddr); ok {
+		mac.Write(ip.AsSlice())
+	}
+	return mac.Sum(nil)[:SERVER_COOKIE_SIZE]
+}
+
+// validCookie reports whether query carries a server cookie this server
+// issued to clientAddr
+func (s *Server) validCookie(query *DNSMessage, clientAddr net.Addr) bool {
+	if len(query.Cookie) <= CLIENT_COOKIE_SIZE {
+		return false
+	}
+	clientCookie := query.Cookie[:CLIENT_COOKIE_SIZE]
+	return hmac.Equal(query.Cookie[CLIENT_COOKIE_SIZE:], s.serverCookie(clientCookie, clientAddr))
+}
+
+// addServerCookie echoes the client cookie together with a fresh server
+// cookie in the OPT record of response
+func (s *Server) addServerCookie(response, query *DNSMessage, clientAddr net.Addr) {
+	clientCookie := query.Cookie[:CLIENT_COOKIE_SIZE]
+	cookie := append(clientCookie[:CLIENT_COOKIE_SIZE:CLIENT_COOKIE_SIZE], s.serverCookie(clientCookie, clientAddr)...)
+
+	for i := range response.Additional {
+		if response.Additional[i].Type == TYPE_OPT {
+			response.Additional[i].Data = EncodeCookieOption(cookie)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dns
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// DoHHandler returns an RFC 8484 DNS-over-HTTPS handler answering from the
+// same pipeline as UDP and TCP. It accepts POST bodies of type
+// application/dns-message and GET requests with a base64url "dns" parameter.
+func (s *Server) DoHHandler() http.Handler {
+	return http.HandlerFunc(s.serveDoH)
+}
+
+// serveDoH answers a single DNS-over-HTTPS request
+func (s *Server) serveDoH(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		data, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			http.Error(w, "invalid dns parameter", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); mediaType != DOH_CONTENT_TYPE {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var err error
+		data, err = io.ReadAll(http.MaxBytesReader(w, r.Body, 0xFFFF))
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var clientAddr net.Addr = &net.TCPAddr{}
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		clientAddr = net.TCPAddrFromAddrPort(addrPort)
+	}
+
+	answered := false
+	s.handleDNSQuery(clientAddr, data, transportDoH, func(response []byte) error {
+		answered = true
+		w.Header().Set("Content-Type", DOH_CONTENT_TYPE)
+		_, err := w.Write(response)
+		return err
+	})
+	if !answered {
+		http.Error(w, "malformed DNS message", http.StatusBadRequest)
+	}
+}
+
+// startDoHServer serves DNS-over-HTTPS at /dns-query in the background
+func (s *Server) startDoHServer() {
+	mux := http.NewServeMux()
+	mux.Handle("/dns-query", s.DoHHandler())
+
+	s.dohServer = &http.Server{
+		Addr:    net.JoinHostPort(s.bindAddr, fmt.Sprint(s.dohPort)),
+		Handler: mux,
+	}
+
+	go func() {
+		s.logger.Info("DoH server started", "port", s.dohPort, "tls", s.dohCertFile != "")
+
+		var err error
+		if s.dohCertFile != "" {
+			err = s.dohServer.ListenAndServeTLS(s.dohCertFile, s.dohKeyFile)
+		} else {
+			err = s.dohServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("DoH server failed", "error", err)
+		}
+	}()
+}
@@ -1,13 +1,13 @@
 package dns
 
 import (
-	"log/slog"
+	"fmt"
 	"strings"
 )
 
 // EncodeDNSMessage encodes a DNS message to bytes
-func EncodeDNSMessage(msg *DNSMessage) []byte {
-	var buffer []byte
+func EncodeDNSMessage(msg *DNSMessage) ([]byte, error) {
+	buffer := make([]byte, 0, encodedSize(msg))
 
 	// Encode the header
 	buffer = append(buffer, byte(msg.Header.ID>>8), byte(msg.Header.ID))
@@ -19,43 +19,175 @@ func EncodeDNSMessage(msg *DNSMessage) []byte {
 
 	// Encode the questions
 	for _, question := range msg.Questions {
-		nameBytes := EncodeDomainName(question.Name)
-		buffer = append(buffer, nameBytes...)
+		var err error
+		if buffer, err = appendDomainName(buffer, question.Name); err != nil {
+			return nil, fmt.Errorf("failed to encode question: %w", err)
+		}
 		buffer = append(buffer, byte(question.Type>>8), byte(question.Type))
 		buffer = append(buffer, byte(question.Class>>8), byte(question.Class))
 	}
 
+	var err error
+
 	// Encode the answers
 	for _, answer := range msg.Answers {
-		buffer = append(buffer, EncodeDomainName(answer.Name)...)
-		buffer = append(buffer, byte(answer.Type>>8), byte(answer.Type))
-		buffer = append(buffer, byte(answer.Class>>8), byte(answer.Class))
-		buffer = append(buffer, byte(answer.TTL>>24), byte(answer.TTL>>16),
-			byte(answer.TTL>>8), byte(answer.TTL))
-		buffer = append(buffer, byte(len(answer.Data)>>8), byte(len(answer.Data)))
-		buffer = append(buffer, answer.Data...)
+		if buffer, err = encodeResourceRecord(buffer, answer); err != nil {
+			return nil, fmt.Errorf("failed to encode answer: %w", err)
+		}
+	}
+
+	// Encode the authority records
+	for _, authority := range msg.Authority {
+		if buffer, err = encodeResourceRecord(buffer, authority); err != nil {
+			return nil, fmt.Errorf("failed to encode authority record: %w", err)
+		}
 	}
 
-	return buffer
+	// Encode the additional records
+	for _, additional := range msg.Additional {
+		if buffer, err = encodeResourceRecord(buffer, additional); err != nil {
+			return nil, fmt.Errorf("failed to encode additional record: %w", err)
+		}
+	}
+
+	return buffer, nil
 }
 
-// EncodeDomainName encodes a domain name to DNS format
-func EncodeDomainName(name string) []byte {
+// encodedSize returns an upper bound on the encoded size of msg, so it can be
+// encoded without growing the buffer. An encoded name is at most two bytes
+// longer than its text form.
+func encodedSize(msg *DNSMessage) int {
+	size := MIN_MESSAGE_SIZE
+	for _, question := range msg.Questions {
+		size += len(question.Name) + 2 + 4
+	}
+	for _, sections := range [][]DNSResourceRecord{msg.Answers, msg.Authority, msg.Additional} {
+		for _, record := range sections {
+			size += recordSize(record)
+		}
+	}
+	return size
+}
+
+// recordSize returns an upper bound on the encoded size of record
+func recordSize(record DNSResourceRecord) int {
+	return len(record.Name) + 2 + 10 + len(record.Data)
+}
+
+// Validate checks that the record's data has the length its type requires
+func (record DNSResourceRecord) Validate() error {
+	switch record.Type {
+	case TYPE_A:
+		if len(record.Data) != 4 {
+			return fmt.Errorf("A record %q has %d bytes of data, want 4", record.Name, len(record.Data))
+		}
+	case TYPE_AAAA:
+		if len(record.Data) != 16 {
+			return fmt.Errorf("AAAA record %q has %d bytes of data, want 16", record.Name, len(record.Data))
+		}
+	}
+	if len(record.Data) > 0xFFFF {
+		return fmt.Errorf("record %q has %d bytes of data, exceeding 65535", record.Name, len(record.Data))
+	}
+	return nil
+}
+
+// encodeResourceRecord appends a resource record to buffer
+func encodeResourceRecord(buffer []byte, record DNSResourceRecord) ([]byte, error) {
+	if err := record.Validate(); err != nil {
+		return nil, err
+	}
+
+	buffer, err := appendDomainName(buffer, record.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer = append(buffer, byte(record.Type>>8), byte(record.Type))
+	buffer = append(buffer, byte(record.Class>>8), byte(record.Class))
+	buffer = append(buffer, byte(record.TTL>>24), byte(record.TTL>>16),
+		byte(record.TTL>>8), byte(record.TTL))
+	buffer = append(buffer, byte(len(record.Data)>>8), byte(len(record.Data)))
+	buffer = append(buffer, record.Data...)
+	return buffer, nil
+}
+
+// EncodeDomainName encodes a domain name to DNS format. A single trailing dot
+// marks the root and "" or "." encode the root itself; empty labels elsewhere
+// are rejected.
+func EncodeDomainName(name string) ([]byte, error) {
+	return appendDomainName(nil, name)
+}
+
+// appendDomainName appends the encoded form of name to buffer
+func appendDomainName(buffer []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
 	if name == "" {
-		return []byte{0} // Empty domain name
+		return append(buffer, 0), nil // Root domain name
 	}
 
-	var buffer []byte
-	labels := strings.Split(name, ".")
-	for _, label := range labels {
+	for rest, more := name, true; more; {
+		var label string
+		label, rest, more = strings.Cut(rest, ".")
+		if label == "" {
+			return nil, fmt.Errorf("empty label in domain name %q", name)
+		}
 		if len(label) > 63 {
-			slog.Warn("Label too long", "label", label)
-			continue
+			return nil, fmt.Errorf("label %q exceeds 63 bytes", label)
 		}
 		buffer = append(buffer, byte(len(label)))
-		buffer = append(buffer, []byte(label)...)
+		buffer = append(buffer, label...)
 	}
 	buffer = append(buffer, 0) // Null byte to end the domain name
 
-	return buffer
+	return buffer, nil
+}
+
+// Encode encodes the MX record to RDATA: the preference followed by the
+// encoded exchange name
+func (mx MXRecord) Encode() ([]byte, error) {
+	exchange, err := EncodeDomainName(mx.Exchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode MX exchange: %w", err)
+	}
+
+	buffer := make([]byte, 0, 2+len(exchange))
+	buffer = append(buffer, byte(mx.Preference>>8), byte(mx.Preference))
+	return append(buffer, exchange...), nil
+}
+
+// Encode encodes the SOA record to RDATA: the two encoded names followed by
+// the five 32-bit timers
+func (soa SOARecord) Encode() ([]byte, error) {
+	mname, err := EncodeDomainName(soa.MName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SOA mname: %w", err)
+	}
+	rname, err := EncodeDomainName(soa.RName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SOA rname: %w", err)
+	}
+
+	buffer := make([]byte, 0, len(mname)+len(rname)+20)
+	buffer = append(buffer, mname...)
+	buffer = append(buffer, rname...)
+	for _, value := range []uint32{soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum} {
+		buffer = append(buffer, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+	return buffer, nil
+}
+
+// EncodeTXTRecord encodes text as TXT RDATA, splitting it into character
+// strings of at most 255 bytes
+func EncodeTXTRecord(text string) []byte {
+	var buffer []byte
+	for {
+		chunk := text[:min(len(text), 255)]
+		buffer = append(buffer, byte(len(chunk)))
+		buffer = append(buffer, chunk...)
+		text = text[len(chunk):]
+		if text == "" {
+			return buffer
+		}
+	}
 }
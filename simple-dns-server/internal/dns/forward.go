@@ -0,0 +1,132 @@
+package dns
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// negativeKey identifies a cached negative answer
+type negativeKey struct {
+	name  string
+	qtype uint16
+}
+
+// negativeEntry is a cached NXDOMAIN or NODATA answer (RFC 2308)
+type negativeEntry struct {
+	rcode   uint8
+	soa     DNSResourceRecord
+	expires time.Time
+}
+
+// negativeCache holds negative upstream answers until their SOA-derived TTL
+// runs out, so repeated lookups of missing names don't reach the upstream
+type negativeCache struct {
+	mu      sync.Mutex
+	entries map[negativeKey]negativeEntry
+}
+
+// get returns the unexpired entry for key and the seconds it has left
+func (c *negativeCache) get(key negativeKey, now time.Time) (negativeEntry, uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return negativeEntry{}, 0, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return negativeEntry{}, 0, false
+	}
+	return entry, uint32(entry.expires.Sub(now) / time.Second), true
+}
+
+// put stores entry under key, dropping expired entries first when the cache
+// is full. New entries are discarded while it stays full.
+func (c *negativeCache) put(key negativeKey, entry negativeEntry, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[negativeKey]negativeEntry)
+	}
+	if len(c.entries) >= MAX_NEGATIVE_CACHE {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= MAX_NEGATIVE_CACHE {
+			return
+		}
+	}
+	c.entries[key] = entry
+}
+
+// negativeTTL returns the SOA of a negative response with its TTL lowered to
+// how long the response may be cached: the smaller of the record's TTL and
+// the SOA minimum field. Responses without an SOA are not cached.
+func negativeTTL(response *DNSMessage) (DNSResourceRecord, uint32, bool) {
+	for _, record := range response.Authority {
+		if record.Type != TYPE_SOA {
+			continue
+		}
+		soa, err := ParseSOARecord(record.Data)
+		if err != nil {
+			return DNSResourceRecord{}, 0, false
+		}
+		record.TTL = min(record.TTL, soa.Minimum)
+		return record, record.TTL, true
+	}
+	return DNSResourceRecord{}, 0, false
+}
+
+// forwardQuestion answers question from the upstream resolver, or from the
+// negative cache when the upstream recently said the name or type does not
+// exist. It adds the answers and any SOA to response and reports whether the
+// name exists upstream.
+func (s *Server) forwardQuestion(question DNSQuestion, response *DNSMessage, logger *slog.Logger) bool {
+	key := negativeKey{name: strings.ToLower(question.Name), qtype: question.Type}
+	now := time.Now()
+
+	if entry, ttl, ok := s.negativeCache.get(key, now); ok {
+		soa := entry.soa
+		soa.TTL = ttl
+		response.Authority = append(response.Authority, soa)
+		response.Header.NSCount++
+		logger.Debug("Negative answer served from cache", "rcode", RcodeName(entry.rcode), "ttl", ttl)
+		return entry.rcode != RCODE_NXDOMAIN
+	}
+
+	upstream, err := s.forwarder.Query(question.Name, question.Type)
+	if err != nil {
+		logger.Error("Forwarding failed", "error", err)
+		response.Header.SetRCODE(RCODE_SERVFAIL)
+		return true // Keep SERVFAIL from being turned into NXDOMAIN
+	}
+
+	rcode := upstream.Header.RCODE()
+	for _, answer := range upstream.Answers {
+		answer.TTL = s.clampTTL(answer.TTL)
+		response.Answers = append(response.Answers, answer)
+		response.Header.ANCount++
+	}
+
+	negative := rcode == RCODE_NXDOMAIN || rcode == RCODE_NOERROR && len(upstream.Answers) == 0
+	if negative {
+		if soa, ttl, ok := negativeTTL(upstream); ok {
+			response.Authority = append(response.Authority, soa)
+			response.Header.NSCount++
+			if ttl > 0 {
+				s.negativeCache.put(key, negativeEntry{rcode: rcode, soa: soa, expires: now.Add(time.Duration(ttl) * time.Second)}, now)
+			}
+		}
+	} else if rcode != RCODE_NOERROR {
+		response.Header.SetRCODE(rcode)
+	}
+
+	logger.Info("Query forwarded", "rcode", RcodeName(rcode), "answer_count", len(upstream.Answers))
+	return rcode != RCODE_NXDOMAIN
+}
@@ -0,0 +1,78 @@
+package dns
+
+// Header flag bits (RFC 1035 section 4.1.1)
+const (
+	FLAG_QR = 1 << 15 // Message is a response
+	FLAG_AA = 1 << 10 // Authoritative answer
+	FLAG_TC = 1 << 9  // Truncated
+	FLAG_RD = 1 << 8  // Recursion desired
+	FLAG_RA = 1 << 7  // Recursion available
+
+	opcodeShift = 11
+	opcodeMask  = 0x0F << opcodeShift
+	rcodeMask   = 0x000F
+)
+
+// QR reports whether the message is a response
+func (h *DNSHeader) QR() bool { return h.flag(FLAG_QR) }
+
+// SetQR marks the message as a response or a query
+func (h *DNSHeader) SetQR(response bool) { h.setFlag(FLAG_QR, response) }
+
+// AA reports whether the answer is authoritative
+func (h *DNSHeader) AA() bool { return h.flag(FLAG_AA) }
+
+// SetAA sets the authoritative answer bit
+func (h *DNSHeader) SetAA(authoritative bool) { h.setFlag(FLAG_AA, authoritative) }
+
+// TC reports whether the message was truncated
+func (h *DNSHeader) TC() bool { return h.flag(FLAG_TC) }
+
+// SetTC sets the truncation bit
+func (h *DNSHeader) SetTC(truncated bool) { h.setFlag(FLAG_TC, truncated) }
+
+// RD reports whether the query asked for recursion
+func (h *DNSHeader) RD() bool { return h.flag(FLAG_RD) }
+
+// SetRD sets the recursion desired bit
+func (h *DNSHeader) SetRD(desired bool) { h.setFlag(FLAG_RD, desired) }
+
+// RA reports whether the server offers recursion
+func (h *DNSHeader) RA() bool { return h.flag(FLAG_RA) }
+
+// SetRA sets the recursion available bit
+func (h *DNSHeader) SetRA(available bool) { h.setFlag(FLAG_RA, available) }
+
+// Opcode returns the 4-bit opcode, e.g. OPCODE_QUERY
+func (h *DNSHeader) Opcode() uint8 {
+	return uint8(h.Flags & opcodeMask >> opcodeShift)
+}
+
+// SetOpcode replaces the opcode; only its low 4 bits are used
+func (h *DNSHeader) SetOpcode(opcode uint8) {
+	h.Flags = h.Flags&^opcodeMask | uint16(opcode)<<opcodeShift&opcodeMask
+}
+
+// RCODE returns the 4-bit response code, e.g. RCODE_NXDOMAIN
+func (h *DNSHeader) RCODE() uint8 {
+	return uint8(h.Flags & rcodeMask)
+}
+
+// SetRCODE replaces the response code; only its low 4 bits are used
+func (h *DNSHeader) SetRCODE(rcode uint8) {
+	h.Flags = h.Flags&^rcodeMask | uint16(rcode)&rcodeMask
+}
+
+// flag reports whether bit is set in the flags
+func (h *DNSHeader) flag(bit uint16) bool {
+	return h.Flags&bit != 0
+}
+
+// setFlag sets or clears bit in the flags
+func (h *DNSHeader) setFlag(bit uint16, on bool) {
+	if on {
+		h.Flags |= bit
+	} else {
+		h.Flags &^= bit
+	}
+}
@@ -0,0 +1,42 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// HealthHandler returns a handler for orchestration probes. /healthz always
+// answers 200 while the process runs; /readyz answers 200 only while the UDP
+// and TCP listeners are bound and serving, and 503 otherwise.
+func (s *Server) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+	return mux
+}
+
+// startHealthServer serves the health endpoints in the background. It starts
+// before the DNS listeners are bound so /readyz can report 503 until then.
+func (s *Server) startHealthServer() {
+	s.healthServer = &http.Server{
+		Addr:    net.JoinHostPort(s.bindAddr, fmt.Sprint(s.healthPort)),
+		Handler: s.HealthHandler(),
+	}
+
+	go func() {
+		s.logger.Info("Health server started", "port", s.healthPort)
+		if err := s.healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Health server failed", "error", err)
+		}
+	}()
+}
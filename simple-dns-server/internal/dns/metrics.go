@@ -0,0 +1,163 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics holds query counters for the DNS server
+type Metrics struct {
+	Queries     atomic.Uint64 // Messages received
+	Answered    atomic.Uint64 // Responses carrying at least one answer
+	NXDomain    atomic.Uint64 // Responses with RCODE NXDOMAIN
+	ParseErrors atomic.Uint64 // Messages that failed to parse
+	Blocked     atomic.Uint64 // Questions answered by the blocklist sinkhole
+
+	queryTypes sync.Map // uint16 -> *atomic.Uint64
+
+	latency latencyHistogram
+}
+
+// latencyBuckets are the upper bounds of the query latency histogram buckets
+var latencyBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// latencyHistogram counts query latencies into latencyBuckets. Every field is
+// updated atomically, so concurrent workers never contend on a lock.
+type latencyHistogram struct {
+	buckets [len(latencyBuckets) + 1]atomic.Uint64 // One per bound, plus +Inf; not cumulative
+	count   atomic.Uint64
+	sumNS   atomic.Uint64
+}
+
+// observe records a single latency
+func (h *latencyHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(latencyBuckets[:], d)
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sumNS.Add(uint64(max(d, 0)))
+}
+
+// LatencyCount returns how many query latencies were recorded
+func (m *Metrics) LatencyCount() uint64 {
+	return m.latency.count.Load()
+}
+
+// observeType counts a question of the given type
+func (m *Metrics) observeType(qtype uint16) {
+	counter, ok := m.queryTypes.Load(qtype)
+	if !ok {
+		counter, _ = m.queryTypes.LoadOrStore(qtype, new(atomic.Uint64))
+	}
+	counter.(*atomic.Uint64).Add(1)
+}
+
+// TypeCount returns how many questions of the given type were received
+func (m *Metrics) TypeCount(qtype uint16) uint64 {
+	if counter, ok := m.queryTypes.Load(qtype); ok {
+		return counter.(*atomic.Uint64).Load()
+	}
+	return 0
+}
+
+// WritePrometheus renders the counters in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	fmt.Fprintln(w, "# HELP dns_queries_total DNS messages received.")
+	fmt.Fprintln(w, "# TYPE dns_queries_total counter")
+	fmt.Fprintf(w, "dns_queries_total %d\n", m.Queries.Load())
+
+	fmt.Fprintln(w, "# HELP dns_answered_total DNS responses with at least one answer.")
+	fmt.Fprintln(w, "# TYPE dns_answered_total counter")
+	fmt.Fprintf(w, "dns_answered_total %d\n", m.Answered.Load())
+
+	fmt.Fprintln(w, "# HELP dns_nxdomain_total DNS responses with RCODE NXDOMAIN.")
+	fmt.Fprintln(w, "# TYPE dns_nxdomain_total counter")
+	fmt.Fprintf(w, "dns_nxdomain_total %d\n", m.NXDomain.Load())
+
+	fmt.Fprintln(w, "# HELP dns_parse_errors_total DNS messages that failed to parse.")
+	fmt.Fprintln(w, "# TYPE dns_parse_errors_total counter")
+	fmt.Fprintf(w, "dns_parse_errors_total %d\n", m.ParseErrors.Load())
+
+	fmt.Fprintln(w, "# HELP dns_blocked_total DNS questions sinkholed by the blocklist.")
+	fmt.Fprintln(w, "# TYPE dns_blocked_total counter")
+	fmt.Fprintf(w, "dns_blocked_total %d\n", m.Blocked.Load())
+
+	var qtypes []uint16
+	m.queryTypes.Range(func(key, _ any) bool {
+		qtypes = append(qtypes, key.(uint16))
+		return true
+	})
+	sort.Slice(qtypes, func(i, j int) bool { return qtypes[i] < qtypes[j] })
+
+	fmt.Fprintln(w, "# HELP dns_query_duration_seconds Time from receiving a DNS message to sending its response.")
+	fmt.Fprintln(w, "# TYPE dns_query_duration_seconds histogram")
+	var cumulative uint64
+	for i, bound := range latencyBuckets {
+		cumulative += m.latency.buckets[i].Load()
+		fmt.Fprintf(w, "dns_query_duration_seconds_bucket{le=\"%g\"} %d\n", bound.Seconds(), cumulative)
+	}
+	cumulative += m.latency.buckets[len(latencyBuckets)].Load()
+	fmt.Fprintf(w, "dns_query_duration_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(w, "dns_query_duration_seconds_sum %g\n", time.Duration(m.latency.sumNS.Load()).Seconds())
+	fmt.Fprintf(w, "dns_query_duration_seconds_count %d\n", m.latency.count.Load())
+
+	fmt.Fprintln(w, "# HELP dns_queries_by_type_total DNS questions received by query type.")
+	fmt.Fprintln(w, "# TYPE dns_queries_by_type_total counter")
+	for _, qtype := range qtypes {
+		fmt.Fprintf(w, "dns_queries_by_type_total{type=%q} %d\n", TypeName(qtype), m.TypeCount(qtype))
+	}
+}
+
+// ServeHTTP serves the counters in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+// TypeName returns the mnemonic for a record type, e.g. "A" or "TYPE65"
+func TypeName(qtype uint16) string {
+	switch qtype {
+	case TYPE_A:
+		return "A"
+	case TYPE_NS:
+		return "NS"
+	case TYPE_CNAME:
+		return "CNAME"
+	case TYPE_SOA:
+		return "SOA"
+	case TYPE_PTR:
+		return "PTR"
+	case TYPE_MX:
+		return "MX"
+	case TYPE_TXT:
+		return "TXT"
+	case TYPE_AAAA:
+		return "AAAA"
+	case TYPE_OPT:
+		return "OPT"
+	case TYPE_AXFR:
+		return "AXFR"
+	case TYPE_ANY:
+		return "ANY"
+	}
+	return fmt.Sprintf("TYPE%d", qtype)
+}
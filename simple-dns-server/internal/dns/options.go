@@ -0,0 +1,188 @@
+package dns
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ServerOption configures optional Server behavior
+type ServerOption func(*Server)
+
+// WithTTLBounds clamps the TTL of every returned answer to [minTTL, maxTTL].
+// A zero bound is treated as unset.
+func WithTTLBounds(minTTL, maxTTL uint32) ServerOption {
+	return func(s *Server) {
+		s.minTTL = minTTL
+		s.maxTTL = maxTTL
+	}
+}
+
+// WithHealthName makes the server answer A queries for HEALTH_NAME with
+// 127.0.0.1 and TTL 0 regardless of the record store, for liveness probes.
+func WithHealthName(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.healthName = enabled
+	}
+}
+
+// WithRecordStore makes the server answer from store instead of the default
+// records.
+func WithRecordStore(store *RecordStore) ServerOption {
+	return func(s *Server) {
+		s.recordStore = store
+	}
+}
+
+// WithFlattenCNAME makes A/AAAA queries follow CNAME records internally and
+// return only the terminal addresses under the queried name.
+func WithFlattenCNAME(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.flattenCNAME = enabled
+	}
+}
+
+// WithVersion sets the version string answered for "version.bind" CH TXT
+// queries. Version queries are not answered when it is empty.
+func WithVersion(version string) ServerOption {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithAllowedNetworks restricts the server to clients inside the given
+// networks; everyone else is answered REFUSED. All clients are allowed when
+// no networks are given, but zone transfers are then refused to everyone.
+func WithAllowedNetworks(networks ...netip.Prefix) ServerOption {
+	return func(s *Server) {
+		s.allowedNetworks = append(s.allowedNetworks, networks...)
+	}
+}
+
+// ParseCIDRs parses IPv4 and IPv6 CIDR blocks such as "10.0.0.0/8" or
+// "fd00::/8" for use with WithAllowedNetworks.
+func ParseCIDRs(cidrs ...string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		network, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
+
+// WithWorkerPool sets how many workers handle UDP queries and how many
+// received packets may wait for them before new ones are dropped.
+func WithWorkerPool(workers, queueSize int) ServerOption {
+	return func(s *Server) {
+		s.workers = max(workers, 1)
+		s.queueSize = max(queueSize, 0)
+	}
+}
+
+// WithMetricsPort serves Prometheus metrics at /metrics on the given port.
+// Metrics are not served by default.
+func WithMetricsPort(port int) ServerOption {
+	return func(s *Server) {
+		s.metricsPort = port
+	}
+}
+
+// WithSOA makes the server authoritative for zone: negative answers for names
+// in the zone carry soa in the authority section so clients can cache them.
+func WithSOA(zone string, soa SOARecord) ServerOption {
+	return func(s *Server) {
+		s.soaZone = strings.ToLower(strings.TrimSuffix(zone, "."))
+		s.soa = &soa
+	}
+}
+
+// WithQueryLog writes a line to log for every answered query. The caller
+// owns log and closes it after stopping the server.
+func WithQueryLog(log *QueryLog) ServerOption {
+	return func(s *Server) {
+		s.queryLog = log
+	}
+}
+
+// WithBlocklist sinkholes every name on list regardless of the record store,
+// answering as mode selects (SINKHOLE_ADDRESS or SINKHOLE_NXDOMAIN).
+func WithBlocklist(list *Blocklist, mode int) ServerOption {
+	return func(s *Server) {
+		s.blocklist = list
+		s.sinkholeMode = mode
+	}
+}
+
+// WithBindAddr binds the UDP and TCP listeners, and the DoH, health and
+// metrics servers, to addr, e.g. "127.0.0.1", instead of all interfaces.
+func WithBindAddr(addr string) ServerOption {
+	return func(s *Server) {
+		s.bindAddr = addr
+	}
+}
+
+// WithAddressFamily restricts the UDP and TCP listeners to FAMILY_IPV4 or
+// FAMILY_IPV6. The default, FAMILY_DUAL, binds both stacks. Combine with
+// WithBindAddr("::1") or similar to listen on a single IPv6 address.
+func WithAddressFamily(family int) ServerOption {
+	return func(s *Server) {
+		s.family = family
+	}
+}
+
+// WithForwarder forwards recursive questions for names missing from the
+// record store to the DNS server at upstream ("host:port" or a bare host) and
+// advertises recursion. NXDOMAIN and NODATA answers are cached for their SOA minimum.
+func WithForwarder(upstream string) ServerOption {
+	return func(s *Server) {
+		s.forwarder = NewResolver(upstream)
+	}
+}
+
+// WithSelfName makes the server answer A and AAAA queries for name with the
+// addresses it is bound on, so clients can discover it. When bound to all
+// interfaces, every interface address is used.
+func WithSelfName(name string) ServerOption {
+	return func(s *Server) {
+		s.selfName = name
+	}
+}
+
+// WithDoH serves DNS-over-HTTPS at /dns-query on the given port. With an empty
+// certFile it serves plain HTTP, for use behind a TLS-terminating proxy.
+func WithDoH(port int, certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.dohPort = port
+		s.dohCertFile = certFile
+		s.dohKeyFile = keyFile
+	}
+}
+
+// WithCookies enables DNS Cookies (RFC 7873): queries carrying a client
+// cookie get it echoed with a server cookie derived from secret and the
+// client address. A nil secret is replaced with a random one, which makes
+// issued cookies invalid after a restart. With requireForLarge, UDP clients
+// without a valid server cookie get responses truncated to 512 bytes so
+// spoofed queries cannot be used for amplification.
+func WithCookies(secret []byte, requireForLarge bool) ServerOption {
+	return func(s *Server) {
+		if len(secret) == 0 {
+			secret = make([]byte, COOKIE_SECRET_SIZE)
+			rand.Read(secret) // Never fails on supported platforms
+		}
+		s.cookieSecret = secret
+		s.requireCookie = requireForLarge
+	}
+}
+
+// WithHealthPort serves /healthz and /readyz over HTTP on the given port. See
+// Server.HealthHandler.
+func WithHealthPort(port int) ServerOption {
+	return func(s *Server) {
+		s.healthPort = port
+	}
+}
@@ -1,15 +1,26 @@
 package dns
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 )
 
+// Errors returned by ParseDNSMessage, wrapped with details; test with errors.Is
+var (
+	ErrMessageTooShort  = errors.New("message too short")
+	ErrUnexpectedEnd    = errors.New("unexpected end of message")
+	ErrInvalidPointer   = errors.New("invalid compression pointer")
+	ErrLabelOverflow    = errors.New("label or name too long")
+	ErrTooManyQuestions = errors.New("too many questions")
+	ErrTrailingData     = errors.New("trailing data after message")
+)
+
 // ParseDNSMessage parses a DNS message from raw bytes
 func ParseDNSMessage(data []byte) (*DNSMessage, error) {
 	if len(data) < MIN_MESSAGE_SIZE {
-		return nil, fmt.Errorf("message too short: %d bytes, minimum %d required", len(data), MIN_MESSAGE_SIZE)
+		return nil, fmt.Errorf("%w: %d bytes, minimum %d required", ErrMessageTooShort, len(data), MIN_MESSAGE_SIZE)
 	}
 
 	msg := &DNSMessage{}
@@ -21,20 +32,62 @@ func ParseDNSMessage(data []byte) (*DNSMessage, error) {
 	msg.Header.NSCount = uint16(data[8])<<8 | uint16(data[9])
 	msg.Header.ARCount = uint16(data[10])<<8 | uint16(data[11])
 
-	fmt.Printf("DNS Header: ID=%d, Flags=%d, QDCount=%d, ANCount=%d, NSCount=%d, ARCount=%d\n",
-		msg.Header.ID, msg.Header.Flags, msg.Header.QDCount,
-		msg.Header.ANCount, msg.Header.NSCount, msg.Header.ARCount)
+	// Reject impossible counts up front rather than failing partway through
+	qdCount := int(msg.Header.QDCount)
+	if qdCount > MAX_QUESTIONS {
+		return nil, fmt.Errorf("%w: count %d exceeds maximum %d", ErrTooManyQuestions, qdCount, MAX_QUESTIONS)
+	}
+	if available := len(data) - MIN_MESSAGE_SIZE; qdCount*MIN_QUESTION_SIZE > available {
+		return nil, fmt.Errorf("%w: message declares %d questions but has only %d bytes after the header", ErrUnexpectedEnd, qdCount, available)
+	}
 
-	offset := 12
-	for range int(msg.Header.QDCount) {
+	offset := MIN_MESSAGE_SIZE
+	for i := range qdCount {
 		question, newOffset, err := parseQuestions(data, offset)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("question %d of %d: %w", i+1, qdCount, err)
 		}
 		msg.Questions = append(msg.Questions, question)
 		offset = newOffset
 	}
 
+	for range int(msg.Header.ANCount) {
+		answer, newOffset, err := parseResourceRecord(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		msg.Answers = append(msg.Answers, answer)
+		offset = newOffset
+	}
+
+	for range int(msg.Header.NSCount) {
+		authority, newOffset, err := parseResourceRecord(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		msg.Authority = append(msg.Authority, authority)
+		offset = newOffset
+	}
+
+	for range int(msg.Header.ARCount) {
+		record, newOffset, err := parseResourceRecord(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if record.Type == TYPE_OPT {
+			msg.UDPSize = record.Class // OPT carries the payload size in CLASS
+			if msg.Cookie, err = parseCookieOption(record.Data); err != nil {
+				return nil, err
+			}
+		}
+		msg.Additional = append(msg.Additional, record)
+		offset = newOffset
+	}
+
+	if offset != len(data) {
+		return nil, fmt.Errorf("%w: %d bytes after the declared records", ErrTrailingData, len(data)-offset)
+	}
+
 	return msg, nil
 }
 
@@ -50,7 +103,7 @@ func parseQuestions(data []byte, offset int) (DNSQuestion, int, error) {
 	slog.Debug("Parsed question name", "name", question.Name)
 
 	if newOffset+4 > len(data) {
-		return question, 0, fmt.Errorf("not enough data for question type and class")
+		return question, 0, fmt.Errorf("%w: no room for question type and class", ErrUnexpectedEnd)
 	}
 
 	question.Type = uint16(data[newOffset])<<8 | uint16(data[newOffset+1])
@@ -64,12 +117,143 @@ func parseQuestions(data []byte, offset int) (DNSQuestion, int, error) {
 	return question, newOffset + 4, nil
 }
 
+func parseResourceRecord(data []byte, offset int) (DNSResourceRecord, int, error) {
+	record := DNSResourceRecord{}
+
+	name, newOffset, err := parseDomainName(data, offset)
+	if err != nil {
+		return record, 0, err
+	}
+	record.Name = name
+
+	if newOffset+10 > len(data) {
+		return record, 0, fmt.Errorf("%w: no room for resource record fields", ErrUnexpectedEnd)
+	}
+
+	record.Type = uint16(data[newOffset])<<8 | uint16(data[newOffset+1])
+	record.Class = uint16(data[newOffset+2])<<8 | uint16(data[newOffset+3])
+	record.TTL = uint32(data[newOffset+4])<<24 | uint32(data[newOffset+5])<<16 |
+		uint32(data[newOffset+6])<<8 | uint32(data[newOffset+7])
+	rdLength := int(uint16(data[newOffset+8])<<8 | uint16(data[newOffset+9]))
+	newOffset += 10
+
+	if newOffset+rdLength > len(data) {
+		return record, 0, fmt.Errorf("%w: resource data extends beyond message", ErrUnexpectedEnd)
+	}
+	record.Data, err = expandRDATA(data, newOffset, newOffset+rdLength, record.Type)
+	if err != nil {
+		return record, 0, err
+	}
+
+	return record, newOffset + rdLength, nil
+}
+
+// expandRDATA returns the RDATA in data[offset:end], with the domain names of
+// the types that may compress them (RFC 3597, section 4) written out in full.
+// Their pointers refer to the whole message, so they would be garbage once
+// the record is re-encoded elsewhere. Other types are returned unchanged.
+func expandRDATA(data []byte, offset, end int, recordType uint16) ([]byte, error) {
+	var fixed, names int // Bytes before the first name, and how many names follow
+	switch recordType {
+	case TYPE_CNAME, TYPE_NS, TYPE_PTR:
+		names = 1
+	case TYPE_MX:
+		fixed, names = 2, 1
+	case TYPE_SOA:
+		names = 2
+	default:
+		return data[offset:end], nil
+	}
+
+	if end-offset < fixed+names {
+		return nil, fmt.Errorf("%w: record data is %d bytes", ErrUnexpectedEnd, end-offset)
+	}
+	expanded := append([]byte(nil), data[offset:offset+fixed]...)
+	offset += fixed
+	for range names {
+		name, next, err := parseDomainName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next > end {
+			return nil, fmt.Errorf("%w: name runs past the record data", ErrUnexpectedEnd)
+		}
+		if expanded, err = appendDomainName(expanded, name); err != nil {
+			return nil, err
+		}
+		offset = next
+	}
+	return append(expanded, data[offset:end]...), nil
+}
+
+// ParseMXRecord parses MX RDATA into its preference and exchange name
+func ParseMXRecord(data []byte) (MXRecord, error) {
+	if len(data) < 3 {
+		return MXRecord{}, fmt.Errorf("%w: MX data is %d bytes", ErrUnexpectedEnd, len(data))
+	}
+
+	exchange, _, err := parseDomainName(data, 2)
+	if err != nil {
+		return MXRecord{}, err
+	}
+
+	return MXRecord{
+		Preference: uint16(data[0])<<8 | uint16(data[1]),
+		Exchange:   exchange,
+	}, nil
+}
+
+// ParseSOARecord parses SOA RDATA into its names and timers
+func ParseSOARecord(data []byte) (SOARecord, error) {
+	mname, offset, err := parseDomainName(data, 0)
+	if err != nil {
+		return SOARecord{}, err
+	}
+	rname, offset, err := parseDomainName(data, offset)
+	if err != nil {
+		return SOARecord{}, err
+	}
+	if len(data)-offset != 20 {
+		return SOARecord{}, fmt.Errorf("SOA timers are %d bytes, want 20", len(data)-offset)
+	}
+
+	timer := func(i int) uint32 {
+		b := data[offset+4*i:]
+		return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	}
+	return SOARecord{
+		MName:   mname,
+		RName:   rname,
+		Serial:  timer(0),
+		Refresh: timer(1),
+		Retry:   timer(2),
+		Expire:  timer(3),
+		Minimum: timer(4),
+	}, nil
+}
+
+// ParseTXTRecord parses TXT RDATA and joins its character strings
+func ParseTXTRecord(data []byte) (string, error) {
+	var text strings.Builder
+	for offset := 0; offset < len(data); {
+		length := int(data[offset])
+		if offset+1+length > len(data) {
+			return "", fmt.Errorf("%w: TXT character string extends beyond data", ErrUnexpectedEnd)
+		}
+		text.Write(data[offset+1 : offset+1+length])
+		offset += 1 + length
+	}
+	return text.String(), nil
+}
+
 func parseDomainName(data []byte, offset int) (string, int, error) {
 	var labels []string
+	nameLength := 1 // The terminating root label
+	start := offset
 
 	for {
 		if offset >= len(data) {
-			return "", 0, fmt.Errorf("unexpected end of data")
+			return "", 0, fmt.Errorf("%w: name runs past the message", ErrUnexpectedEnd)
 		}
 
 		length := data[offset]
@@ -84,28 +268,46 @@ func parseDomainName(data []byte, offset int) (string, int, error) {
 		// 0xC0 = 11000000
 		if length&0xC0 == 0xC0 {
 			if offset+1 >= len(data) {
-				return "", 0, fmt.Errorf("invalid compression pointer")
+				return "", 0, fmt.Errorf("%w: truncated at offset %d", ErrInvalidPointer, offset)
 			}
 			// 0x3F = 00111111
 			pointer := int(uint16(length&0x3F)<<8 | uint16(data[offset+1]))
+			// The target must lie before the name containing the pointer, so
+			// every hop moves strictly backwards and loops are impossible
+			if pointer >= start {
+				return "", 0, fmt.Errorf("%w: offset %d points to %d, not before the name at %d", ErrInvalidPointer, offset, pointer, start)
+			}
 			name, _, err := parseDomainName(data, pointer)
 			if err != nil {
 				return "", 0, err
 			}
-			labels = append(labels, strings.Split(name, ".")...)
+			if name != "." {
+				nameLength += len(name) + 1
+				labels = append(labels, strings.Split(name, ".")...)
+			}
 			offset += 2
 			break
 		}
 
+		// 0x40 and 0x80 prefixes are reserved label types
+		if length > 63 {
+			return "", 0, fmt.Errorf("%w: label length %d exceeds 63", ErrLabelOverflow, length)
+		}
+
 		if offset+int(length)+1 > len(data) {
-			return "", 0, fmt.Errorf("label extends beyond data")
+			return "", 0, fmt.Errorf("%w: label extends beyond message", ErrUnexpectedEnd)
 		}
 
 		label := string(data[offset+1 : offset+1+int(length)])
 		labels = append(labels, label)
+		nameLength += int(length) + 1
 		offset += int(length) + 1
 	}
 
+	if nameLength > MAX_NAME_LENGTH {
+		return "", 0, fmt.Errorf("%w: name is %d bytes, maximum %d", ErrLabelOverflow, nameLength, MAX_NAME_LENGTH)
+	}
+
 	if len(labels) == 0 {
 		return ".", offset, nil
 	}
@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// storedRecordSet is the JSON form of the records of one name and type
+type storedRecordSet struct {
+	Type uint16   `json:"type"`
+	TTL  uint32   `json:"ttl"`
+	Data [][]byte `json:"data"` // RDATA, base64 encoded
+}
+
+// RecordStoreOption configures a RecordStore
+type RecordStoreOption func(*RecordStore)
+
+// WithAutoSave saves the store to path after every AddRecord and
+// RemoveRecord that changes it, so runtime changes survive a restart. Failed
+// saves are logged and leave the in-memory change in place.
+func WithAutoSave(path string) RecordStoreOption {
+	return func(rs *RecordStore) {
+		rs.autoSavePath = path
+	}
+}
+
+// SaveToFile writes every record in the store to path as JSON. The file is
+// replaced atomically, so a crash mid-save leaves the previous contents.
+func (rs *RecordStore) SaveToFile(path string) error {
+	rs.mu.RLock()
+	data, err := rs.encodeLocked()
+	rs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return writeRecordsFile(path, data)
+}
+
+// encodeLocked returns the JSON form of the store. The caller must hold rs.mu.
+func (rs *RecordStore) encodeLocked() ([]byte, error) {
+	stored := make(map[string][]storedRecordSet, len(rs.records))
+	for domain, types := range rs.records {
+		sets := make([]storedRecordSet, 0, len(types))
+		for recordType, set := range types {
+			sets = append(sets, storedRecordSet{Type: recordType, TTL: set.ttl, Data: set.data})
+		}
+		sort.Slice(sets, func(i, j int) bool { return sets[i].Type < sets[j].Type })
+		stored[domain] = sets
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+	return data, nil
+}
+
+// writeRecordsFile replaces path with data through a temporary file in the
+// same directory
+func writeRecordsFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+	return nil
+}
+
+// LoadFromFile replaces the contents of the store with the records saved at
+// path by SaveToFile. On error the store is left unchanged.
+func (rs *RecordStore) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+
+	var stored map[string][]storedRecordSet
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse records %s: %w", path, err)
+	}
+
+	records := make(map[string]map[uint16]*recordSet, len(stored))
+	rotation := make(map[string]*atomic.Uint64, len(stored))
+	for name, sets := range stored {
+		// Lookups are case-insensitive, so keys are stored lowercased as
+		// AddRecord does; sets for names differing only in case are merged
+		domain := strings.ToLower(name)
+		for _, set := range sets {
+			for _, rdata := range set.Data {
+				record := DNSResourceRecord{Name: domain, Type: set.Type, Data: rdata}
+				if err := record.Validate(); err != nil {
+					return fmt.Errorf("invalid record in %s: %w", path, err)
+				}
+			}
+			if len(set.Data) == 0 {
+				continue
+			}
+			if records[domain] == nil {
+				records[domain] = make(map[uint16]*recordSet)
+				rotation[domain] = new(atomic.Uint64)
+			}
+			existing := records[domain][set.Type]
+			if existing == nil {
+				records[domain][set.Type] = &recordSet{data: set.Data, ttl: set.TTL}
+				continue
+			}
+			for _, rdata := range set.Data {
+				if !slices.ContainsFunc(existing.data, func(d []byte) bool { return bytes.Equal(d, rdata) }) {
+					existing.data = append(existing.data, rdata)
+				}
+			}
+		}
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.records = records
+	rs.rotation = rotation
+	return nil
+}
+
+// autoSave saves the store if WithAutoSave is set. The caller must not hold
+// rs.mu, so lookups and changes carry on while the file is written. Each save
+// captures the store when it starts, and a change already covered by a save
+// that started after it is not saved again, so a burst of changes costs few
+// writes and the file never goes back to older contents.
+func (rs *RecordStore) autoSave() {
+	if rs.autoSavePath == "" {
+		return
+	}
+	rs.unsaved.Store(true)
+
+	rs.saveMu.Lock()
+	defer rs.saveMu.Unlock()
+
+	if !rs.unsaved.Swap(false) {
+		return
+	}
+	if err := rs.SaveToFile(rs.autoSavePath); err != nil {
+		rs.unsaved.Store(true) // Retried with the next change
+		slog.Error("Failed to auto-save records", "path", rs.autoSavePath, "error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// QueryLogEntry is one line of the query log
+type QueryLogEntry struct {
+	Time      time.Time `json:"time"`
+	ClientIP  string    `json:"client_ip"`
+	Name      string    `json:"qname"`
+	Type      string    `json:"qtype"`
+	RCODE     uint16    `json:"rcode"`
+	LatencyUS int64     `json:"latency_us"`
+}
+
+// QueryLog appends JSON lines to a file, rotating it to "<path>.1" once it
+// grows past maxSize bytes. It is safe for concurrent use.
+type QueryLog struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// NewQueryLog opens or creates the query log at path. A maxSize of zero
+// disables rotation.
+func NewQueryLog(path string, maxSize int64) (*QueryLog, error) {
+	l := &QueryLog{
+		path:    path,
+		maxSize: maxSize,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write appends entry to the log, rotating first if it would exceed the size
+// limit. When rotation fails the entry still goes to the current file and the
+// rotation error is returned.
+func (l *QueryLog) Write(entry QueryLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode query log entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var rotateErr error
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		rotateErr = l.rotate()
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write query log: %w", err)
+	}
+	return rotateErr
+}
+
+// Close closes the log file
+func (l *QueryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// open opens the log file for appending and records its current size. The
+// caller must hold l.mu or have exclusive access.
+func (l *QueryLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open query log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat query log: %w", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to "<path>.1", replacing any previous one,
+// and starts a new file. The old file stays open until the new one is, so a
+// failure leaves the log writing where it was. The caller must hold l.mu.
+func (l *QueryLog) rotate() error {
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate query log: %w", err)
+	}
+	old := l.file
+	if err := l.open(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("failed to close rotated query log: %w", err)
+	}
+	return nil
+}
@@ -1,57 +1,273 @@
 package dns
 
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
 // RecordStore manages DNS records in memory
 type RecordStore struct {
-	records map[string]map[uint16][]byte
+	mu       sync.RWMutex
+	records  map[string]map[uint16]*recordSet
+	rotation map[string]*atomic.Uint64 // per-name counter for round-robin ordering
+
+	autoSavePath string      // file rewritten after every change, if set
+	saveMu       sync.Mutex  // serializes auto-saves, which run without mu held
+	unsaved      atomic.Bool // a change has been made since the last auto-save began
+}
+
+// recordSet holds the records of one name and type, which share a TTL
+type recordSet struct {
+	data [][]byte
+	ttl  uint32
 }
 
 // NewRecordStore creates a new DNS record store with default records
-func NewRecordStore() *RecordStore {
-	return &RecordStore{
-		records: map[string]map[uint16][]byte{
-			"www.example.com": {
-				TYPE_A: []byte{192, 168, 1, 1}, // 192.168.1.1
-			},
-			"example.com": {
-				TYPE_A: []byte{192, 168, 1, 1}, // 192.168.1.1
-			},
-			"test.com": {
-				TYPE_A: []byte{10, 0, 0, 1}, // 10.0.0.1
-			},
-			"localhost": {
-				TYPE_A: []byte{127, 0, 0, 1}, // 127.0.0.1
-			},
-			"google.com": {
-				TYPE_A: []byte{8, 8, 8, 8}, // 8.8.8.8 (example)
-			},
-		},
-	}
-}
-
-// LookupRecord looks up a DNS record by domain name and type
+func NewRecordStore(opts ...RecordStoreOption) *RecordStore {
+	rs := &RecordStore{
+		records:  make(map[string]map[uint16]*recordSet),
+		rotation: make(map[string]*atomic.Uint64),
+	}
+
+	// Options apply after the defaults so they are not auto-saved
+	defer func() {
+		for _, opt := range opts {
+			opt(rs)
+		}
+	}()
+
+	rs.AddRecord("www.example.com", TYPE_A, []byte{192, 168, 1, 1}) // 192.168.1.1
+	rs.AddRecord("example.com", TYPE_A, []byte{192, 168, 1, 1})     // 192.168.1.1
+	rs.AddRecord("test.com", TYPE_A, []byte{10, 0, 0, 1})           // 10.0.0.1
+	rs.AddRecord("localhost", TYPE_A, []byte{127, 0, 0, 1})         // 127.0.0.1
+	rs.AddRecord("localhost", TYPE_AAAA, net.IPv6loopback)          // ::1
+	rs.AddRecord("google.com", TYPE_A, []byte{8, 8, 8, 8})          // 8.8.8.8 (example)
+
+	return rs
+}
+
+// LookupRecord looks up the first DNS record by domain name and type
 func (rs *RecordStore) LookupRecord(domain string, recordType uint16) ([]byte, bool) {
-	if domainRecords, exists := rs.records[domain]; exists {
-		if data, hasType := domainRecords[recordType]; hasType {
-			return data, true
+	domain = strings.ToLower(domain)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	if domainRecords, exists := rs.records[rs.ownerName(domain)]; exists {
+		if set, hasType := domainRecords[recordType]; hasType {
+			return set.data[0], true
 		}
 	}
 	return nil, false
 }
 
-// AddRecord adds a DNS record to the store
+// LookupRecords looks up all DNS records by domain name and type along with
+// their TTL. The order rotates on every call so clients spread across the
+// returned records. Names without records of their own are answered from a
+// matching wildcard.
+func (rs *RecordStore) LookupRecords(domain string, recordType uint16) ([][]byte, uint32, bool) {
+	domain = strings.ToLower(domain)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	domain = rs.ownerName(domain)
+	domainRecords, exists := rs.records[domain]
+	if !exists {
+		return nil, 0, false
+	}
+	set, hasType := domainRecords[recordType]
+	if !hasType {
+		return nil, 0, false
+	}
+
+	start := int((rs.rotation[domain].Add(1) - 1) % uint64(len(set.data)))
+	rotated := make([][]byte, 0, len(set.data))
+	rotated = append(rotated, set.data[start:]...)
+	rotated = append(rotated, set.data[:start]...)
+	return rotated, set.ttl, true
+}
+
+// LookupAll returns every record stored for domain keyed by type, answering
+// from a matching wildcard like LookupRecords
+func (rs *RecordStore) LookupAll(domain string) map[uint16][][]byte {
+	domain = strings.ToLower(domain)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	all := make(map[uint16][][]byte)
+	for recordType, set := range rs.records[rs.ownerName(domain)] {
+		all[recordType] = append([][]byte(nil), set.data...)
+	}
+	return all
+}
+
+// ZoneRecords returns every record at or below zone, sorted by name and
+// type. Wildcard owners are returned as stored, e.g. "*.example.com".
+func (rs *RecordStore) ZoneRecords(zone string) []DNSResourceRecord {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	var records []DNSResourceRecord
+	for name, types := range rs.records {
+		if zone != "" && name != zone && !strings.HasSuffix(name, "."+zone) {
+			continue
+		}
+		for recordType, set := range types {
+			for _, data := range set.data {
+				records = append(records, DNSResourceRecord{
+					Name:  name,
+					Type:  recordType,
+					Class: CLASS_IN,
+					TTL:   set.ttl,
+					Data:  data,
+				})
+			}
+		}
+	}
+
+	slices.SortStableFunc(records, func(a, b DNSResourceRecord) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type))
+	})
+	return records
+}
+
+// HasName reports whether the store holds any record for domain, directly or
+// through a wildcard
+func (rs *RecordStore) HasName(domain string) bool {
+	domain = strings.ToLower(domain)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	_, exists := rs.records[rs.ownerName(domain)]
+	return exists
+}
+
+// ownerName returns the name whose records answer for domain: domain itself
+// if it has records, otherwise the wildcard "*.<closest encloser>" (RFC 4592).
+// The caller must hold rs.mu.
+func (rs *RecordStore) ownerName(domain string) string {
+	if _, exists := rs.records[domain]; exists {
+		return domain
+	}
+
+	for name := domain; ; {
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return domain
+		}
+		name = name[dot+1:]
+
+		if _, exists := rs.records["*."+name]; exists {
+			return "*." + name
+		}
+		if _, exists := rs.records[name]; exists {
+			return domain // The closest encloser has no wildcard
+		}
+	}
+}
+
+// AddRecord adds a DNS record with DEFAULT_TTL to the store. Domain names are
+// case-insensitive and stored lowercased. Adding the same data twice for a
+// domain and type is a no-op.
 func (rs *RecordStore) AddRecord(domain string, recordType uint16, data []byte) {
+	rs.AddRecordTTL(domain, recordType, data, DEFAULT_TTL)
+}
+
+// AddRecordTTL adds a DNS record with the given TTL to the store. All records
+// of a domain and type share one TTL (RFC 2181), so ttl also applies to the
+// records already stored for them.
+func (rs *RecordStore) AddRecordTTL(domain string, recordType uint16, data []byte, ttl uint32) {
+	if rs.addRecord(strings.ToLower(domain), recordType, data, ttl) {
+		rs.autoSave()
+	}
+}
+
+// addRecord stores a record under the lowercased domain and reports whether
+// the store changed
+func (rs *RecordStore) addRecord(domain string, recordType uint16, data []byte, ttl uint32) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
 	if rs.records[domain] == nil {
-		rs.records[domain] = make(map[uint16][]byte)
+		rs.records[domain] = make(map[uint16]*recordSet)
+		rs.rotation[domain] = new(atomic.Uint64)
 	}
-	rs.records[domain][recordType] = data
+	set := rs.records[domain][recordType]
+	if set == nil {
+		set = &recordSet{}
+		rs.records[domain][recordType] = set
+	}
+	changed := set.ttl != ttl
+	set.ttl = ttl
+
+	for _, existing := range set.data {
+		if bytes.Equal(existing, data) {
+			return changed
+		}
+	}
+	set.data = append(set.data, data)
+	return true
 }
 
-// RemoveRecord removes a DNS record from the store
+// AddPTRForA adds an A record for domain together with the matching PTR
+// record under the reverse name of ip
+func (rs *RecordStore) AddPTRForA(domain string, ip []byte) error {
+	target, err := EncodeDomainName(domain)
+	if err != nil {
+		return err
+	}
+
+	rs.AddRecord(domain, TYPE_A, ip)
+	rs.AddRecord(ReverseName(ip), TYPE_PTR, target)
+	return nil
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name used to look up ip
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	const hexDigits = "0123456789abcdef"
+	labels := make([]string, 0, 2*net.IPv6len+2)
+	for i := len(ip) - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip[i]&0x0F]), string(hexDigits[ip[i]>>4]))
+	}
+	return strings.Join(append(labels, "ip6", "arpa"), ".")
+}
+
+// RemoveRecord removes all DNS records of a type from the store
 func (rs *RecordStore) RemoveRecord(domain string, recordType uint16) {
-	if domainRecords, exists := rs.records[domain]; exists {
-		delete(domainRecords, recordType)
-		if len(domainRecords) == 0 {
-			delete(rs.records, domain)
-		}
+	if rs.removeRecord(strings.ToLower(domain), recordType) {
+		rs.autoSave()
+	}
+}
+
+// removeRecord deletes the records of the lowercased domain and type and
+// reports whether there were any
+func (rs *RecordStore) removeRecord(domain string, recordType uint16) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	domainRecords := rs.records[domain]
+	if _, exists := domainRecords[recordType]; !exists {
+		return false
+	}
+	delete(domainRecords, recordType)
+	if len(domainRecords) == 0 {
+		delete(rs.records, domain)
+		delete(rs.rotation, domain)
 	}
+	return true
 }
@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net"
+	"time"
+)
+
+// NewQueryID returns a random 16-bit message ID. IDs come from crypto/rand so
+// off-path attackers cannot predict them; math/rand is used only if the
+// system source fails.
+func NewQueryID() uint16 {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uint16(mathrand.IntN(1 << 16))
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// Resolver sends queries to a single DNS server over UDP
+type Resolver struct {
+	server  string
+	timeout time.Duration
+}
+
+// ResolverOption configures optional behavior on a Resolver
+type ResolverOption func(*Resolver)
+
+// WithResolverTimeout sets how long each attempt waits for a response
+func WithResolverTimeout(timeout time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.timeout = timeout
+	}
+}
+
+// NewResolver creates a resolver for server, given as "host:port" or a bare
+// host which then uses the standard DNS port
+func NewResolver(server string, opts ...ResolverOption) *Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, fmt.Sprint(STANDARD_DNS_PORT))
+	}
+
+	r := &Resolver{
+		server:  server,
+		timeout: DEFAULT_RESOLVER_TIMEOUT,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Server returns the "host:port" address queries are sent to
+func (r *Resolver) Server() string {
+	return r.server
+}
+
+// Lookup queries the server for name and type and returns the answers. A
+// timed-out attempt is retried once.
+func (r *Resolver) Lookup(name string, qtype uint16) ([]DNSResourceRecord, error) {
+	response, err := r.Query(name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	if rcode := response.Header.RCODE(); rcode != RCODE_NOERROR {
+		return nil, fmt.Errorf("lookup %s: server returned RCODE %d", name, rcode)
+	}
+	return response.Answers, nil
+}
+
+// Query sends a question for name and type and returns the whole response,
+// whatever its RCODE. A timed-out attempt is retried once.
+func (r *Resolver) Query(name string, qtype uint16) (*DNSMessage, error) {
+	id := NewQueryID()
+	query, err := NewQuery(id, name, qtype)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	response, err := r.exchange(query, id)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		response, err = r.exchange(query, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", name, err)
+	}
+	return response, nil
+}
+
+// exchange sends query over UDP and returns the first response carrying id.
+// Datagrams with another ID, which may be spoofed, are discarded and reading
+// continues until the deadline.
+func (r *Resolver) exchange(query []byte, id uint16) (*DNSMessage, error) {
+	conn, err := net.Dial("udp", r.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(r.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, EDNS_UDP_SIZE)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := ParseDNSMessage(buffer[:n])
+		if err != nil || response.Header.ID != id {
+			continue
+		}
+		return response, nil
+	}
+}
@@ -1,69 +1,443 @@
 package dns
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// QueryHandler inspects a question and optionally answers it. Returning false
+// falls through to the default record store lookup.
+type QueryHandler func(q DNSQuestion) ([]DNSResourceRecord, bool)
+
 // Server represents a DNS server
 type Server struct {
-	port        int
-	conn        *net.UDPConn
-	recordStore *RecordStore
-	logger      *slog.Logger
+	bindAddr     string
+	family       int
+	port         int
+	mu           sync.Mutex // guards the sockets, HTTP servers and selfAddrs set by Start
+	conn         *net.UDPConn
+	listener     *net.TCPListener
+	storeMu      sync.RWMutex
+	recordStore  *RecordStore
+	logger       *slog.Logger
+	queryHandler QueryHandler
+	minTTL       uint32
+	maxTTL       uint32
+	healthName   bool
+	flattenCNAME bool
+	version      string
+
+	allowedNetworks []netip.Prefix
+
+	workers        int
+	queueSize      int
+	droppedPackets atomic.Uint64
+
+	metrics       Metrics
+	metricsPort   int
+	metricsServer *http.Server
+
+	soaZone string
+	soa     *SOARecord
+
+	queryLog *QueryLog
+
+	blocklist    *Blocklist
+	sinkholeMode int
+
+	dohPort     int
+	dohCertFile string
+	dohKeyFile  string
+	dohServer   *http.Server
+
+	cookieSecret  []byte
+	requireCookie bool
+
+	selfName  string
+	selfAddrs []net.IP // Bound addresses served for selfName, set by Start
+
+	forwarder     *Resolver
+	negativeCache negativeCache
+
+	healthPort   int
+	healthServer *http.Server
+	ready        atomic.Bool // UDP and TCP listeners are serving
+}
+
+// readBufferPool holds EDNS_UDP_SIZE buffers for reading datagrams
+var readBufferPool = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, EDNS_UDP_SIZE)
+		return &buffer
+	},
+}
+
+// transport is how a query reached the server
+type transport int
+
+const (
+	transportUDP transport = iota
+	transportTCP
+	transportDoH
+)
+
+// udpJob is a received datagram waiting for a worker
+type udpJob struct {
+	clientAddr *net.UDPAddr
+	data       []byte
 }
 
 // NewServer creates a new DNS server
-func NewServer(port int, logger *slog.Logger) *Server {
-	return &Server{
+func NewServer(port int, logger *slog.Logger, opts ...ServerOption) *Server {
+	s := &Server{
 		port:        port,
 		recordStore: NewRecordStore(),
 		logger:      logger,
+		workers:     DEFAULT_WORKERS,
+		queueSize:   DEFAULT_QUEUE_SIZE,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// SetQueryHandler registers a handler consulted before the record store for
+// every question. It must be called before Start.
+func (s *Server) SetQueryHandler(handler QueryHandler) {
+	s.queryHandler = handler
+}
+
+// records returns the record store currently answering queries
+func (s *Server) records() *RecordStore {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	return s.recordStore
+}
+
+// ReloadRecords loads the records file at path into a new store and swaps it
+// in for the current one. On error the current store keeps serving.
+func (s *Server) ReloadRecords(path string) error {
+	current := s.records()
+	store := NewRecordStore(WithAutoSave(current.autoSavePath))
+	if err := store.LoadFromFile(path); err != nil {
+		s.logger.Error("Failed to reload records", "path", path, "error", err)
+		return err
 	}
+	s.mu.Lock()
+	s.addSelfRecords(store)
+	s.mu.Unlock()
+
+	s.storeMu.Lock()
+	s.recordStore = store
+	s.storeMu.Unlock()
+
+	s.logger.Info("Records reloaded", "path", path)
+	return nil
 }
 
-// Start starts the DNS server
+// Start starts the DNS server on UDP and TCP and blocks until it is stopped
 func (s *Server) Start() error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", s.port))
+	address := net.JoinHostPort(s.bindAddr, strconv.Itoa(s.port))
+	if err := s.bind(address); err != nil {
+		return err
+	}
+
+	s.logger.Info("DNS Server started",
+		"address", address,
+		"message_size", MESSAGE_SIZE)
+
+	s.ready.Store(true)
+	defer s.ready.Store(false)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.serveUDP()
+	}()
+	go func() {
+		defer wg.Done()
+		s.serveTCP()
+	}()
+	wg.Wait()
+
+	return nil
+}
+
+// bind opens the UDP and TCP sockets on address and starts the HTTP servers.
+// It holds s.mu throughout so a concurrent Stop sees either none or all of
+// them.
+func (s *Server) bind(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	udpNetwork, tcpNetwork, err := s.networks()
+	if err != nil {
+		return err
+	}
+
+	if s.healthPort > 0 {
+		s.startHealthServer()
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(udpNetwork, address)
 	if err != nil {
 		return fmt.Errorf("failed to resolve UDP address: %w", err)
 	}
 
-	s.conn, err = net.ListenUDP("udp", addr)
+	s.conn, err = net.ListenUDP(udpNetwork, udpAddr)
 	if err != nil {
 		return fmt.Errorf("failed to listen on UDP: %w", err)
 	}
 
-	s.logger.Info("DNS Server started",
-		"port", s.port,
-		"message_size", MESSAGE_SIZE)
+	tcpAddr, err := net.ResolveTCPAddr(tcpNetwork, address)
+	if err != nil {
+		s.conn.Close()
+		return fmt.Errorf("failed to resolve TCP address: %w", err)
+	}
+
+	s.listener, err = net.ListenTCP(tcpNetwork, tcpAddr)
+	if err != nil {
+		s.conn.Close()
+		return fmt.Errorf("failed to listen on TCP: %w", err)
+	}
+
+	if s.selfName != "" {
+		s.selfAddrs = boundAddrs(s.conn.LocalAddr().(*net.UDPAddr).IP)
+		s.addSelfRecords(s.records())
+	}
+
+	if s.metricsPort > 0 {
+		s.startMetricsServer()
+	}
+	if s.dohPort > 0 {
+		s.startDoHServer()
+	}
+	return nil
+}
+
+// boundAddrs returns the addresses a socket bound to ip is reachable on:
+// ip itself, or every interface address when ip is unspecified
+func boundAddrs(ip net.IP) []net.IP {
+	if !ip.IsUnspecified() {
+		return []net.IP{ip}
+	}
+
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var addrs []net.IP
+	for _, addr := range ifaceAddrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+			addrs = append(addrs, ipNet.IP)
+		}
+	}
+	return addrs
+}
+
+// addSelfRecords adds A and AAAA records for selfName pointing at the bound
+// addresses to store. The caller must hold s.mu.
+func (s *Server) addSelfRecords(store *RecordStore) {
+	for _, ip := range s.selfAddrs {
+		if ip4 := ip.To4(); ip4 != nil {
+			store.AddRecord(s.selfName, TYPE_A, ip4)
+		} else {
+			store.AddRecord(s.selfName, TYPE_AAAA, ip.To16())
+		}
+	}
+}
+
+// networks returns the UDP and TCP networks for the configured address family
+func (s *Server) networks() (udp, tcp string, err error) {
+	switch s.family {
+	case FAMILY_DUAL:
+		return "udp", "tcp", nil
+	case FAMILY_IPV4:
+		return "udp4", "tcp4", nil
+	case FAMILY_IPV6:
+		return "udp6", "tcp6", nil
+	}
+	return "", "", fmt.Errorf("unknown address family: %d", s.family)
+}
+
+// Metrics returns the server's query counters
+func (s *Server) Metrics() *Metrics {
+	return &s.metrics
+}
+
+// startMetricsServer serves the metrics over HTTP in the background
+func (s *Server) startMetricsServer() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &s.metrics)
+
+	s.metricsServer = &http.Server{
+		Addr:    net.JoinHostPort(s.bindAddr, fmt.Sprint(s.metricsPort)),
+		Handler: mux,
+	}
+
+	go func() {
+		s.logger.Info("Metrics server started", "port", s.metricsPort)
+		if err := s.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Metrics server failed", "error", err)
+		}
+	}()
+}
+
+// DroppedPackets returns how many datagrams were dropped because the worker
+// queue was full
+func (s *Server) DroppedPackets() uint64 {
+	return s.droppedPackets.Load()
+}
+
+// serveUDP reads datagrams until the UDP socket is closed and hands them to a
+// fixed pool of workers, dropping packets when the queue is full
+func (s *Server) serveUDP() {
+	jobs := make(chan udpJob, s.queueSize)
+
+	var workers sync.WaitGroup
+	for range s.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				s.handleDNSQuery(job.clientAddr, job.data, transportUDP, func(response []byte) error {
+					_, err := s.conn.WriteToUDP(response, job.clientAddr)
+					return err
+				})
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		workers.Wait()
+	}()
 
 	for {
-		buffer := make([]byte, MESSAGE_SIZE)
-		n, clientAddr, err := s.conn.ReadFromUDP(buffer)
+		buffer := readBufferPool.Get().(*[]byte)
+		n, clientAddr, err := s.conn.ReadFromUDP(*buffer)
+		// The job outlives this iteration, so it gets its own exact-size copy
+		data := slices.Clone((*buffer)[:n])
+		readBufferPool.Put(buffer)
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			s.logger.Error("Error reading from UDP",
 				"error", err,
 				"client_addr", clientAddr)
 			continue
 		}
 
-		go s.handleDNSQuery(clientAddr, buffer[:n])
+		select {
+		case jobs <- udpJob{clientAddr: clientAddr, data: data}:
+		default:
+			s.droppedPackets.Add(1)
+			s.logger.Debug("Worker queue full, dropping packet",
+				"client_addr", clientAddr.String())
+		}
+	}
+}
+
+// serveTCP accepts connections until the TCP listener is closed
+func (s *Server) serveTCP() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Error("Error accepting TCP connection", "error", err)
+			continue
+		}
+
+		go s.handleTCPConn(conn)
+	}
+}
+
+// handleTCPConn serves length-prefixed DNS messages on a TCP connection
+// (RFC 7766) until the client closes it or it stays idle too long.
+func (s *Server) handleTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(TCP_IDLE_TIMEOUT))
+
+		var lengthPrefix [2]byte
+		if _, err := io.ReadFull(conn, lengthPrefix[:]); err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.logger.Debug("Closing TCP connection",
+					"client_addr", conn.RemoteAddr().String(),
+					"error", err)
+			}
+			return
+		}
+
+		data := make([]byte, binary.BigEndian.Uint16(lengthPrefix[:]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			s.logger.Warn("Failed to read TCP DNS message",
+				"client_addr", conn.RemoteAddr().String(),
+				"error", err)
+			return
+		}
+
+		s.handleDNSQuery(conn.RemoteAddr(), data, transportTCP, func(response []byte) error {
+			frame := make([]byte, 2, 2+len(response))
+			binary.BigEndian.PutUint16(frame, uint16(len(response)))
+			_, err := conn.Write(append(frame, response...))
+			return err
+		})
 	}
 }
 
 // Stop stops the DNS server
 func (s *Server) Stop() error {
+	s.ready.Store(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
 	if s.conn != nil {
-		return s.conn.Close()
+		errs = append(errs, s.conn.Close())
 	}
-	return nil
+	if s.listener != nil {
+		errs = append(errs, s.listener.Close())
+	}
+	if s.metricsServer != nil {
+		errs = append(errs, s.metricsServer.Close())
+	}
+	if s.dohServer != nil {
+		errs = append(errs, s.dohServer.Close())
+	}
+	if s.healthServer != nil {
+		errs = append(errs, s.healthServer.Close())
+	}
+	return errors.Join(errs...)
 }
 
-// handleDNSQuery handles a single DNS query
-func (s *Server) handleDNSQuery(clientAddr *net.UDPAddr, data []byte) {
+// handleDNSQuery handles a single DNS query and sends the response with write.
+// UDP responses are truncated to the payload size the client can accept.
+func (s *Server) handleDNSQuery(clientAddr net.Addr, data []byte, via transport, write func([]byte) error) {
+	start := time.Now()
+	defer func() { s.metrics.latency.observe(time.Since(start)) }()
+
 	queryLogger := s.logger.With(
 		"client_addr", clientAddr.String(),
 		"query_size", len(data))
@@ -71,15 +445,61 @@ func (s *Server) handleDNSQuery(clientAddr *net.UDPAddr, data []byte) {
 	queryLogger.Debug("Received DNS query",
 		"data_hex", fmt.Sprintf("%x", data))
 
+	// Every response, whichever path produced it, goes to the query log
+	var question *DNSQuestion
+	var rcode uint8
+	responded := false
+	defer func() {
+		if responded {
+			s.logQuery(start, clientAddr, question, rcode, queryLogger)
+		}
+	}()
+
+	s.metrics.Queries.Add(1)
+
 	if len(data) < MIN_MESSAGE_SIZE {
 		queryLogger.Warn("DNS message too short",
 			"min_size", MIN_MESSAGE_SIZE)
 		return
 	}
 
+	if !s.isAllowed(clientAddr) {
+		queryLogger.Warn("Refusing query from client outside allowed networks")
+
+		response := &DNSMessage{
+			Header: DNSHeader{ID: uint16(data[0])<<8 | uint16(data[1])},
+		}
+		response.Header.SetQR(true)
+		response.Header.SetOpcode(data[2] >> 3) // Echo the opcode from the raw header
+		response.Header.SetRCODE(RCODE_REFUSED)
+		rcode, responded = RCODE_REFUSED, true
+		if err := write(s.encodeResponse(response, queryLogger)); err != nil {
+			queryLogger.Error("Failed to send DNS response", "error", err)
+		}
+		return
+	}
+
 	msg, err := ParseDNSMessage(data)
 	if err != nil {
 		queryLogger.Error("Failed to parse DNS message", "error", err)
+		s.metrics.ParseErrors.Add(1)
+
+		// Answering a malformed response could set off a loop with its sender
+		if data[2]&byte(FLAG_QR>>8) != 0 {
+			queryLogger.Warn("Dropping malformed DNS response")
+			return
+		}
+
+		// The header is intact, so tell the client instead of letting it time out
+		response := &DNSMessage{
+			Header: DNSHeader{ID: uint16(data[0])<<8 | uint16(data[1])},
+		}
+		response.Header.SetQR(true)
+		response.Header.SetRCODE(RCODE_FORMERR)
+		rcode, responded = RCODE_FORMERR, true
+		if err := write(s.encodeResponse(response, queryLogger)); err != nil {
+			queryLogger.Error("Failed to send DNS response", "error", err)
+		}
 		return
 	}
 
@@ -94,11 +514,46 @@ func (s *Server) handleDNSQuery(clientAddr *net.UDPAddr, data []byte) {
 			return ""
 		}())
 
+	if len(msg.Questions) > 0 {
+		question = &msg.Questions[0]
+	}
+
+	if len(msg.Questions) == 1 && msg.Questions[0].Type == TYPE_AXFR {
+		rcode, responded = s.handleAXFR(msg, clientAddr, via, write, queryLogger), true
+		return
+	}
+
 	response := s.createDNSResponse(msg)
+	if s.cookieSecret != nil && msg.Cookie != nil {
+		s.addServerCookie(response, msg, clientAddr)
+	}
 
-	responseBytes := EncodeDNSMessage(response)
-	_, err = s.conn.WriteToUDP(responseBytes, clientAddr)
-	if err != nil {
+	if response.Header.ANCount > 0 {
+		s.metrics.Answered.Add(1)
+	}
+	if response.Header.RCODE() == RCODE_NXDOMAIN {
+		s.metrics.NXDomain.Add(1)
+	}
+
+	responseBytes := s.encodeResponse(response, queryLogger)
+	limit := udpSizeLimit(msg)
+	if s.requireCookie && !s.validCookie(msg, clientAddr) {
+		limit = MESSAGE_SIZE // Unverified clients may be spoofed, so don't amplify
+	}
+	if via == transportUDP && len(responseBytes) > limit {
+		queryLogger.Debug("Truncating UDP response",
+			"response_size", len(responseBytes),
+			"limit", limit)
+		response.Answers = nil
+		response.Header.ANCount = 0
+		response.Authority = nil
+		response.Header.NSCount = 0
+		response.Header.SetTC(true) // The client should retry over TCP
+		responseBytes = s.encodeResponse(response, queryLogger)
+	}
+
+	rcode, responded = response.Header.RCODE(), true
+	if err := write(responseBytes); err != nil {
 		queryLogger.Error("Failed to send DNS response", "error", err)
 		return
 	}
@@ -114,51 +569,402 @@ func (s *Server) handleDNSQuery(clientAddr *net.UDPAddr, data []byte) {
 		"answer_count", response.Header.ANCount)
 }
 
+// logQuery writes an entry for a query answered with rcode to the query log,
+// if one is configured. question is nil when the query could not be parsed.
+func (s *Server) logQuery(start time.Time, clientAddr net.Addr, question *DNSQuestion, rcode uint8, logger *slog.Logger) {
+	if s.queryLog == nil {
+		return
+	}
+
+	entry := QueryLogEntry{
+		Time:      start,
+		RCODE:     uint16(rcode),
+		LatencyUS: time.Since(start).Microseconds(),
+	}
+	if ip, ok := clientIP(clientAddr); ok {
+		entry.ClientIP = ip.String()
+	}
+	if question != nil {
+		entry.Name = question.Name
+		entry.Type = TypeName(question.Type)
+	}
+	if err := s.queryLog.Write(entry); err != nil {
+		logger.Error("Failed to write query log", "error", err)
+	}
+}
+
+// isAllowed reports whether clientAddr may query the server
+func (s *Server) isAllowed(clientAddr net.Addr) bool {
+	if len(s.allowedNetworks) == 0 {
+		return true
+	}
+
+	ip, ok := clientIP(clientAddr)
+	if !ok {
+		return false
+	}
+
+	for _, network := range s.allowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP extracts the IP address of a UDP or TCP client, unmapping
+// IPv4-mapped IPv6 addresses
+func clientIP(clientAddr net.Addr) (netip.Addr, bool) {
+	switch addr := clientAddr.(type) {
+	case *net.UDPAddr:
+		return addr.AddrPort().Addr().Unmap(), true
+	case *net.TCPAddr:
+		return addr.AddrPort().Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// encodeResponse encodes response, falling back to a header-only SERVFAIL
+// when it holds data that cannot be put on the wire
+func (s *Server) encodeResponse(response *DNSMessage, logger *slog.Logger) []byte {
+	responseBytes, err := EncodeDNSMessage(response)
+	if err == nil {
+		return responseBytes
+	}
+
+	logger.Error("Failed to encode DNS response", "error", err)
+
+	failure := &DNSMessage{
+		Header: DNSHeader{
+			ID:    response.Header.ID,
+			Flags: response.Header.Flags,
+		},
+	}
+	failure.Header.SetRCODE(RCODE_SERVFAIL)
+	responseBytes, _ = EncodeDNSMessage(failure) // A bare header always encodes
+	return responseBytes
+}
+
 // createDNSResponse creates a DNS response for the given query
 func (s *Server) createDNSResponse(query *DNSMessage) *DNSMessage {
 	response := &DNSMessage{
 		Header: DNSHeader{
 			ID:      query.Header.ID,
-			Flags:   0x8180, // Standard query response with no error 1000 0001 1000 0000
-			QDCount: query.Header.QDCount,
-			ANCount: 0,
-			NSCount: 0,
-			ARCount: 0,
+			QDCount: uint16(len(query.Questions)), // One entry per echoed question
 		},
 		Questions: query.Questions,
 	}
+	response.Header.SetQR(true)
+	response.Header.SetRD(query.Header.RD())
+	response.Header.SetRA(s.forwarder != nil) // Recursion is only offered by forwarding
 
 	responseLogger := s.logger.With("query_id", query.Header.ID)
 
+	// Only standard queries are supported
+	if opcode := query.Header.Opcode(); opcode != OPCODE_QUERY {
+		response.Header.Flags = 0
+		response.Header.SetQR(true)
+		response.Header.SetOpcode(opcode) // Echo the opcode
+		response.Header.SetRCODE(RCODE_NOTIMP)
+		responseLogger.Warn("Unsupported opcode", "opcode", opcode)
+		return response
+	}
+
+	if len(query.Questions) == 0 {
+		response.Header.SetRCODE(RCODE_FORMERR) // A query must carry at least one question
+		responseLogger.Warn("Query has no questions")
+		return response
+	}
+
+	nameExists := false
 	for _, question := range query.Questions {
 		questionLogger := responseLogger.With(
 			"domain", question.Name,
 			"type", question.Type,
 			"class", question.Class)
 
-		if question.Type == TYPE_A && question.Class == CLASS_IN {
-			domainName := strings.ToLower(question.Name)
-			if ipData, found := s.recordStore.LookupRecord(domainName, TYPE_A); found {
-				answer := DNSResourceRecord{
-					Name:  question.Name,
-					Type:  TYPE_A,
-					Class: CLASS_IN,
-					TTL:   DEFAULT_TTL,
-					Data:  ipData,
-				}
-				response.Answers = append(response.Answers, answer)
+		s.metrics.observeType(question.Type)
+
+		if s.blocklist != nil && s.blocklist.Blocked(question.Name) {
+			s.metrics.Blocked.Add(1)
+			questionLogger.Info("Blocked query sinkholed")
+			if s.sinkholeMode == SINKHOLE_NXDOMAIN {
+				continue
+			}
+
+			// Other types get NODATA so clients don't fall back elsewhere
+			nameExists = true
+			var sinkhole []byte
+			switch question.Type {
+			case TYPE_A:
+				sinkhole = net.IPv4zero.To4()
+			case TYPE_AAAA:
+				sinkhole = net.IPv6zero
+			default:
+				continue
+			}
+			response.Answers = append(response.Answers, DNSResourceRecord{
+				Name:  question.Name,
+				Type:  question.Type,
+				Class: CLASS_IN,
+				TTL:   s.clampTTL(DEFAULT_TTL),
+				Data:  sinkhole,
+			})
+			response.Header.ANCount++
+			continue
+		}
+
+		if s.healthName && question.Type == TYPE_A && strings.ToLower(question.Name) == HEALTH_NAME {
+			response.Answers = append(response.Answers, DNSResourceRecord{
+				Name:  question.Name,
+				Type:  TYPE_A,
+				Class: CLASS_IN,
+				TTL:   0,
+				Data:  []byte{127, 0, 0, 1},
+			})
+			response.Header.ANCount++
+			nameExists = true
+			questionLogger.Debug("Health probe answered")
+			continue
+		}
+
+		if s.version != "" && question.Class == CLASS_CH && question.Type == TYPE_TXT &&
+			strings.ToLower(question.Name) == VERSION_NAME {
+			response.Answers = append(response.Answers, DNSResourceRecord{
+				Name:  question.Name,
+				Type:  TYPE_TXT,
+				Class: CLASS_CH,
+				TTL:   0,
+				Data:  EncodeTXTRecord(s.version),
+			})
+			response.Header.ANCount++
+			nameExists = true
+			questionLogger.Debug("Version query answered")
+			continue
+		}
+
+		if s.soa != nil && question.Class == CLASS_IN && question.Type == TYPE_SOA &&
+			s.soaZone == strings.ToLower(strings.TrimSuffix(question.Name, ".")) {
+			if record, err := s.soaRecord(); err == nil {
+				record.Name = question.Name
+				response.Answers = append(response.Answers, record)
 				response.Header.ANCount++
+				nameExists = true
+				questionLogger.Debug("SOA query answered")
+				continue
+			}
+		}
+
+		if s.queryHandler != nil {
+			if answers, handled := s.queryHandler(question); handled {
+				for _, answer := range answers {
+					answer.TTL = s.clampTTL(answer.TTL)
+					response.Answers = append(response.Answers, answer)
+				}
+				response.Header.ANCount += uint16(len(answers))
+				nameExists = true
+				questionLogger.Info("Query answered by handler",
+					"answer_count", len(answers))
+				continue
+			}
+		}
+
+		domainName := strings.ToLower(question.Name)
+		if s.records().HasName(domainName) {
+			nameExists = true
+		} else if s.forwarder != nil && query.Header.RD() && question.Class == CLASS_IN {
+			if s.forwardQuestion(question, response, questionLogger) {
+				nameExists = true
+			}
+			continue
+		}
+
+		if question.Class == CLASS_IN {
+			// ANY is answered with every type stored for the name
+			qtypes := []uint16{question.Type}
+			if question.Type == TYPE_ANY {
+				qtypes = slices.Sorted(maps.Keys(s.records().LookupAll(domainName)))
+			}
+
+			answered := false
+			for _, qtype := range qtypes {
+				records, ttl, found := s.records().LookupRecords(domainName, qtype)
+				if !found && s.flattenCNAME && (qtype == TYPE_A || qtype == TYPE_AAAA) {
+					records, ttl, _ = s.resolveCNAMEChain(domainName, qtype)
+				}
+				for _, data := range records {
+					answer := DNSResourceRecord{
+						Name:  question.Name,
+						Type:  qtype,
+						Class: CLASS_IN,
+						TTL:   s.clampTTL(ttl),
+						Data:  data,
+					}
+					response.Answers = append(response.Answers, answer)
+					response.Header.ANCount++
+
+					questionLogger.Info("DNS record found",
+						"type", TypeName(qtype),
+						"data", formatRecordData(qtype, data),
+						"ttl", answer.TTL)
+				}
+				answered = answered || len(records) > 0
+			}
 
-				questionLogger.Info("DNS record found",
-					"ip", fmt.Sprintf("%d.%d.%d.%d", ipData[0], ipData[1], ipData[2], ipData[3]),
-					"ttl", answer.TTL)
+			// Point at the authoritative name servers of the zone being served
+			if answered && question.Type != TYPE_NS {
+				for _, ns := range s.zoneNameServers(domainName) {
+					response.Authority = append(response.Authority, ns)
+					response.Header.NSCount++
+				}
 			}
 		}
 	}
 
-	if response.Header.ANCount == 0 {
-		response.Header.Flags |= 0x0003 // Set the "NXDOMAIN" flag // NXDOMAIN（Non-Existent Domain）0000 0000 0000 0011
+	// Echo EDNS0 support so the client knows larger responses are possible
+	if query.UDPSize > 0 {
+		response.Additional = append(response.Additional, DNSResourceRecord{
+			Name:  "", // Root
+			Type:  TYPE_OPT,
+			Class: EDNS_UDP_SIZE,
+		})
+		response.Header.ARCount++
+	}
+
+	// A name that exists without records of the asked type is NOERROR with no
+	// answers (NODATA), not NXDOMAIN
+	if response.Header.ANCount == 0 && !nameExists {
+		response.Header.SetRCODE(RCODE_NXDOMAIN)
+	}
+
+	// Negative answers carry the zone's SOA so clients can cache them
+	if response.Header.ANCount == 0 && s.soa != nil && s.inSOAZone(query.Questions[0].Name) {
+		if record, err := s.soaRecord(); err == nil {
+			response.Authority = append(response.Authority, record)
+			response.Header.NSCount++
+		} else {
+			responseLogger.Error("Failed to encode SOA record", "error", err)
+		}
 	}
 
 	return response
 }
+
+// inSOAZone reports whether name lies within the zone configured with WithSOA
+func (s *Server) inSOAZone(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return s.soaZone == "" || name == s.soaZone || strings.HasSuffix(name, "."+s.soaZone)
+}
+
+// soaRecord returns the configured SOA as a resource record owned by the zone
+// apex. Its TTL is the SOA minimum, the negative caching TTL.
+func (s *Server) soaRecord() (DNSResourceRecord, error) {
+	data, err := s.soa.Encode()
+	if err != nil {
+		return DNSResourceRecord{}, err
+	}
+	return DNSResourceRecord{
+		Name:  s.soaZone,
+		Type:  TYPE_SOA,
+		Class: CLASS_IN,
+		TTL:   s.soa.Minimum,
+		Data:  data,
+	}, nil
+}
+
+// zoneNameServers returns the NS records of the closest enclosing zone of
+// domain, walking up one label at a time.
+func (s *Server) zoneNameServers(domain string) []DNSResourceRecord {
+	name := domain
+	for name != "" {
+		if nameServers, ttl, found := s.records().LookupRecords(name, TYPE_NS); found {
+			records := make([]DNSResourceRecord, 0, len(nameServers))
+			for _, data := range nameServers {
+				records = append(records, DNSResourceRecord{
+					Name:  name,
+					Type:  TYPE_NS,
+					Class: CLASS_IN,
+					TTL:   s.clampTTL(ttl),
+					Data:  data,
+				})
+			}
+			return records
+		}
+
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	return nil
+}
+
+// formatRecordData renders record data for logging
+func formatRecordData(recordType uint16, data []byte) string {
+	switch recordType {
+	case TYPE_A, TYPE_AAAA:
+		return net.IP(data).String()
+	case TYPE_CNAME, TYPE_NS, TYPE_PTR:
+		if name, _, err := parseDomainName(data, 0); err == nil {
+			return name
+		}
+	case TYPE_TXT:
+		if text, err := ParseTXTRecord(data); err == nil {
+			return text
+		}
+	case TYPE_MX:
+		if mx, err := ParseMXRecord(data); err == nil {
+			return fmt.Sprintf("%d %s", mx.Preference, mx.Exchange)
+		}
+	case TYPE_SOA:
+		if soa, err := ParseSOARecord(data); err == nil {
+			return fmt.Sprintf("%s %s %d %d %d %d %d", soa.MName, soa.RName,
+				soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+		}
+	}
+	return fmt.Sprintf("%x", data)
+}
+
+// udpSizeLimit returns the largest UDP response the client accepts: 512 bytes
+// unless a larger size was advertised via EDNS0, capped at EDNS_UDP_SIZE.
+func udpSizeLimit(query *DNSMessage) int {
+	return min(max(int(query.UDPSize), MESSAGE_SIZE), EDNS_UDP_SIZE)
+}
+
+// clampTTL limits ttl to the configured minimum and maximum
+func (s *Server) clampTTL(ttl uint32) uint32 {
+	if s.maxTTL > 0 && ttl > s.maxTTL {
+		return s.maxTTL
+	}
+	if s.minTTL > 0 && ttl < s.minTTL {
+		return s.minTTL
+	}
+	return ttl
+}
+
+// resolveCNAMEChain follows CNAME records starting at domain until it reaches a
+// name holding records of recordType, and returns those records' data and TTL.
+func (s *Server) resolveCNAMEChain(domain string, recordType uint16) ([][]byte, uint32, bool) {
+	name := domain
+	for range MAX_CNAME_DEPTH {
+		target, found := s.records().LookupRecord(name, TYPE_CNAME)
+		if !found {
+			return nil, 0, false
+		}
+
+		targetName, _, err := parseDomainName(target, 0)
+		if err != nil {
+			s.logger.Warn("Invalid CNAME target", "domain", name, "error", err)
+			return nil, 0, false
+		}
+		name = strings.ToLower(targetName)
+
+		if data, ttl, found := s.records().LookupRecords(name, recordType); found {
+			return data, ttl, true
+		}
+	}
+
+	s.logger.Warn("CNAME chain too long", "domain", domain, "max_depth", MAX_CNAME_DEPTH)
+	return nil, 0, false
+}
@@ -1,20 +1,79 @@
 package dns
 
+import "time"
+
 // DNS Record Types
 const (
 	TYPE_A     = 1
 	TYPE_NS    = 2
 	TYPE_CNAME = 5
+	TYPE_SOA   = 6
+	TYPE_PTR   = 12
+	TYPE_MX    = 15
+	TYPE_TXT   = 16
 	TYPE_AAAA  = 28
+	TYPE_OPT   = 41  // EDNS0 pseudo-record
+	TYPE_AXFR  = 252 // QTYPE asking for a full zone transfer
+	TYPE_ANY   = 255 // QTYPE asking for every record of a name
 	CLASS_IN   = 1
+	CLASS_CH   = 3 // CHAOS, used for server diagnostics
+)
+
+// DNS Opcodes
+const (
+	OPCODE_QUERY  = 0
+	OPCODE_IQUERY = 1
+	OPCODE_STATUS = 2
+)
+
+// DNS Response Codes
+const (
+	RCODE_NOERROR  = 0
+	RCODE_FORMERR  = 1
+	RCODE_SERVFAIL = 2
+	RCODE_NXDOMAIN = 3
+	RCODE_NOTIMP   = 4
+	RCODE_REFUSED  = 5
 )
 
 // Server constants
 const (
-	DNS_PORT         = 8053
-	MESSAGE_SIZE     = 512
-	MIN_MESSAGE_SIZE = 12
-	DEFAULT_TTL      = 300 // Default TTL for DNS records in seconds
+	DNS_PORT                 = 8053
+	STANDARD_DNS_PORT        = 53 // Port assumed for a resolver server given without one
+	MESSAGE_SIZE             = 512
+	EDNS_UDP_SIZE            = 4096 // Largest UDP payload accepted and advertised with EDNS0
+	MIN_MESSAGE_SIZE         = 12
+	MIN_QUESTION_SIZE        = 5   // Root name plus type and class
+	MAX_NAME_LENGTH          = 255 // Largest encoded domain name (RFC 1035)
+	MAX_QUESTIONS            = 100 // Largest question count a message may declare
+	DEFAULT_TTL              = 300 // Default TTL for DNS records in seconds
+	HEALTH_NAME              = "health.check"
+	VERSION_NAME             = "version.bind"
+	MAX_CNAME_DEPTH          = 8                // Maximum number of CNAME hops followed when flattening
+	TCP_IDLE_TIMEOUT         = 10 * time.Second // Idle time before a TCP connection is closed
+	DEFAULT_WORKERS          = 64               // Goroutines handling UDP queries
+	DEFAULT_QUEUE_SIZE       = 1024             // Received UDP packets waiting for a worker
+	DEFAULT_RESOLVER_TIMEOUT = 2 * time.Second  // Time the resolver waits for each response
+	DOH_CONTENT_TYPE         = "application/dns-message"
+	MAX_NEGATIVE_CACHE       = 10000 // Negative answers cached from the forwarder
+	AXFR_MESSAGE_SIZE        = 16384 // Target size of each message in a zone transfer
+	MAX_TCP_MESSAGE_SIZE     = 65535 // Largest message the TCP length prefix can frame
+)
+
+// EDNS0 option codes and DNS Cookie sizes (RFC 7873)
+const (
+	EDNS_OPTION_COOKIE = 10
+	CLIENT_COOKIE_SIZE = 8
+	SERVER_COOKIE_SIZE = 16 // Size of the cookies this server issues
+	MIN_SERVER_COOKIE  = 8  // Smallest server cookie a client may send
+	MAX_SERVER_COOKIE  = 32 // Largest server cookie a client may send
+	COOKIE_SECRET_SIZE = 32 // Bytes of random secret generated when none is given
+)
+
+// Sinkhole modes for names on the blocklist
+const (
+	SINKHOLE_ADDRESS  = iota // Answer A with 0.0.0.0 and AAAA with ::
+	SINKHOLE_NXDOMAIN        // Answer NXDOMAIN
 )
 
 // DNSHeader represents the header of a DNS message
@@ -43,9 +102,38 @@ type DNSResourceRecord struct {
 	Data  []byte // Data of the resource record (IP address, etc.)
 }
 
+// MXRecord represents the RDATA of an MX record
+type MXRecord struct {
+	Preference uint16 // Lower values are preferred
+	Exchange   string // Domain name of the mail server
+}
+
+// SOARecord represents the RDATA of an SOA record
+type SOARecord struct {
+	MName   string // Primary name server of the zone
+	RName   string // Mailbox of the zone administrator, with the @ as a dot
+	Serial  uint32 // Version of the zone
+	Refresh uint32 // Seconds before secondaries refresh the zone
+	Retry   uint32 // Seconds before secondaries retry a failed refresh
+	Expire  uint32 // Seconds after which secondaries stop answering for the zone
+	Minimum uint32 // TTL for negative responses
+}
+
 // DNSMessage represents a complete DNS message
 type DNSMessage struct {
-	Header    DNSHeader           // Header of the DNS message
-	Questions []DNSQuestion       // List of questions in the DNS message
-	Answers   []DNSResourceRecord // List of answers in the DNS message
+	Header     DNSHeader           // Header of the DNS message
+	Questions  []DNSQuestion       // List of questions in the DNS message
+	Answers    []DNSResourceRecord // List of answers in the DNS message
+	Authority  []DNSResourceRecord // List of authority records in the DNS message
+	Additional []DNSResourceRecord // List of additional records in the DNS message
+
+	UDPSize uint16 // UDP payload size advertised in an EDNS0 OPT record, 0 without one
+	Cookie  []byte // COOKIE option data: the client cookie, then any server cookie
 }
+
+// Address families the DNS listeners bind, see WithAddressFamily
+const (
+	FAMILY_DUAL = iota // IPv4 and IPv6 on one socket where the OS allows it
+	FAMILY_IPV4        // IPv4 only
+	FAMILY_IPV6        // IPv6 only
+)
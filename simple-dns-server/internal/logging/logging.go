@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log output formats accepted by NewHandler
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+)
+
+// ParseLevel maps "debug", "info", "warn" or "error" to its slog level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q: want debug, info, warn or error", level)
+}
+
+// NewHandler returns a handler writing to w at the given level, as JSON or
+// text depending on format. Source locations are included.
+func NewHandler(w io.Writer, level, format string) (slog.Handler, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:     lvl,
+		AddSource: true,
+	}
+	switch strings.ToLower(format) {
+	case FORMAT_JSON:
+		return slog.NewJSONHandler(w, opts), nil
+	case FORMAT_TEXT:
+		return slog.NewTextHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q: want json or text", format)
+}
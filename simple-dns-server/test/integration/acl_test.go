@@ -0,0 +1,54 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerACL(t *testing.T) {
+	tests := []struct {
+		name        string
+		port        int
+		cidrs       []string
+		wantRCODE   uint16
+		wantAnswers int
+	}{
+		{
+			name:        "in_range_served",
+			port:        8071,
+			cidrs:       []string{"127.0.0.0/8", "::1/128"},
+			wantRCODE:   dns.RCODE_NOERROR,
+			wantAnswers: 1,
+		},
+		{
+			name:        "out_of_range_refused",
+			port:        8072,
+			cidrs:       []string{"10.0.0.0/8", "fd00::/8"},
+			wantRCODE:   dns.RCODE_REFUSED,
+			wantAnswers: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := dns.ParseCIDRs(tt.cidrs...)
+			if err != nil {
+				t.Fatalf("ParseCIDRs() error = %v", err)
+			}
+			startTestServer(t, dns.NewServer(tt.port, newTestLogger(), dns.WithAllowedNetworks(networks...)))
+
+			response := exchange(t, tt.port, buildQuery(t, 0x9001, "www.example.com", dns.TYPE_A))
+
+			if response.Header.ID != 0x9001 {
+				t.Errorf("Response ID = %v, want %v", response.Header.ID, 0x9001)
+			}
+			if rcode := response.Header.Flags & 0x000F; rcode != tt.wantRCODE {
+				t.Errorf("Response RCODE = %v, want %v", rcode, tt.wantRCODE)
+			}
+			if len(response.Answers) != tt.wantAnswers {
+				t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), tt.wantAnswers)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerANYQuery(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("multi.example.com", dns.TYPE_A, []byte{192, 0, 2, 10})
+	store.AddRecord("multi.example.com", dns.TYPE_TXT, dns.EncodeTXTRecord("v=spf1 -all"))
+
+	testPort := 8090
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6001, "multi.example.com", dns.TYPE_ANY))
+
+	if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NOERROR {
+		t.Errorf("Response RCODE = %v, want %v (NOERROR)", rcode, dns.RCODE_NOERROR)
+	}
+	if response.Header.ANCount != 2 || len(response.Answers) != 2 {
+		t.Fatalf("Response answers = %d/%d, want 2/2", response.Header.ANCount, len(response.Answers))
+	}
+
+	// Answers come in type order
+	if response.Answers[0].Type != dns.TYPE_A || response.Answers[1].Type != dns.TYPE_TXT {
+		t.Errorf("Answer types = %v, %v, want %v, %v",
+			response.Answers[0].Type, response.Answers[1].Type, dns.TYPE_A, dns.TYPE_TXT)
+	}
+
+	t.Run("unknown_name", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x6002, "missing.example.com", dns.TYPE_ANY))
+		if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NXDOMAIN {
+			t.Errorf("Response RCODE = %v, want %v (NXDOMAIN)", rcode, dns.RCODE_NXDOMAIN)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+// transferZone sends an AXFR query over TCP and collects the answers of every
+// message until the closing SOA, or the first message if it carries none.
+func transferZone(t *testing.T, port int, zone string) (*dns.DNSMessage, []dns.DNSResourceRecord) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("Error connecting to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	query := buildQuery(t, 0x7001, zone, dns.TYPE_AXFR)
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		t.Fatalf("Error sending query: %v", err)
+	}
+
+	var first *dns.DNSMessage
+	var records []dns.DNSResourceRecord
+	for {
+		var lengthPrefix [2]byte
+		if _, err := io.ReadFull(conn, lengthPrefix[:]); err != nil {
+			t.Fatalf("Error reading length prefix: %v", err)
+		}
+		data := make([]byte, binary.BigEndian.Uint16(lengthPrefix[:]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			t.Fatalf("Error reading response: %v", err)
+		}
+		msg, err := dns.ParseDNSMessage(data)
+		if err != nil {
+			t.Fatalf("Error parsing response: %v", err)
+		}
+		if first == nil {
+			first = msg
+		}
+		records = append(records, msg.Answers...)
+
+		if len(records) == 0 || len(records) > 1 && records[len(records)-1].Type == dns.TYPE_SOA {
+			return first, records
+		}
+	}
+}
+
+func TestDNSServerAXFR(t *testing.T) {
+	store := dns.NewRecordStore()
+	for i := 1; i <= 600; i++ { // Enough to span several messages
+		store.AddRecord(fmt.Sprintf("host%d.zone.test", i), dns.TYPE_A, []byte{10, 1, byte(i >> 8), byte(i)})
+	}
+	store.AddRecord("zone.test", dns.TYPE_TXT, dns.EncodeTXTRecord("v=spf1 -all"))
+
+	soa := dns.SOARecord{
+		MName: "ns1.zone.test", RName: "admin.zone.test",
+		Serial: 2024010101, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 300,
+	}
+	loopback, err := dns.ParseCIDRs("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPort := 8102
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store),
+		dns.WithSOA("zone.test", soa), dns.WithAllowedNetworks(loopback...)))
+
+	first, records := transferZone(t, testPort, "zone.test")
+	if rcode := first.Header.RCODE(); rcode != dns.RCODE_NOERROR {
+		t.Fatalf("Response RCODE = %v, want %v (NOERROR)", rcode, dns.RCODE_NOERROR)
+	}
+	if !first.Header.AA() {
+		t.Errorf("Response AA = false, want true")
+	}
+
+	// SOA, 601 zone records, SOA; default records outside the zone are excluded
+	if len(records) != 603 {
+		t.Fatalf("Transferred %d records, want 603", len(records))
+	}
+	if records[0].Type != dns.TYPE_SOA || records[len(records)-1].Type != dns.TYPE_SOA {
+		t.Errorf("Transfer should start and end with SOA, got %v ... %v", records[0].Type, records[len(records)-1].Type)
+	}
+	names := make(map[string]bool)
+	for _, record := range records[1 : len(records)-1] {
+		names[record.Name] = true
+	}
+	if !names["host1.zone.test"] || !names["host600.zone.test"] || !names["zone.test"] || names["www.example.com"] {
+		t.Errorf("Transferred names are wrong: %d distinct names", len(names))
+	}
+
+	t.Run("other_zone_refused", func(t *testing.T) {
+		first, _ := transferZone(t, testPort, "example.com")
+		if rcode := first.Header.RCODE(); rcode != dns.RCODE_REFUSED {
+			t.Errorf("Response RCODE = %v, want %v (REFUSED)", rcode, dns.RCODE_REFUSED)
+		}
+	})
+
+	t.Run("udp_refused", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x7002, "zone.test", dns.TYPE_AXFR))
+		if rcode := response.Header.RCODE(); rcode != dns.RCODE_REFUSED {
+			t.Errorf("Response RCODE = %v, want %v (REFUSED)", rcode, dns.RCODE_REFUSED)
+		}
+	})
+}
+
+func TestDNSServerAXFRRequiresACL(t *testing.T) {
+	testPort := 8103
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithSOA("example.com", dns.SOARecord{MName: "ns1.example.com", RName: "admin.example.com", Minimum: 60})))
+
+	first, _ := transferZone(t, testPort, "example.com")
+	if rcode := first.Header.RCODE(); rcode != dns.RCODE_REFUSED {
+		t.Errorf("Response RCODE = %v, want %v (REFUSED) without allowed networks", rcode, dns.RCODE_REFUSED)
+	}
+}
+
+func TestDNSServerAXFRLargeRecords(t *testing.T) {
+	// The second record would take the first message past 65535 bytes if it
+	// were added just because the message was still under the target size
+	store := dns.NewRecordStore()
+	store.AddRecord("a.zone.test", dns.TYPE_TXT, bytes.Repeat([]byte{'a'}, 16000))
+	store.AddRecord("b.zone.test", dns.TYPE_TXT, bytes.Repeat([]byte{'b'}, 60000))
+
+	loopback, err := dns.ParseCIDRs("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPort := 8104
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store),
+		dns.WithSOA("zone.test", dns.SOARecord{MName: "ns1.zone.test", RName: "admin.zone.test", Minimum: 60}),
+		dns.WithAllowedNetworks(loopback...)))
+
+	_, records := transferZone(t, testPort, "zone.test")
+	if len(records) != 4 {
+		t.Fatalf("Transferred %d records, want 4", len(records))
+	}
+	if len(records[2].Data) != 60000 {
+		t.Errorf("Large record has %d bytes of data, want 60000", len(records[2].Data))
+	}
+}
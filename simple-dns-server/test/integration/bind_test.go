@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerBindAddr(t *testing.T) {
+	testPort := 8086
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithBindAddr("127.0.0.1")))
+
+	response := exchange(t, testPort, buildQuery(t, 0xa101, "www.example.com", dns.TYPE_A))
+
+	if response.Header.ANCount != 1 || len(response.Answers) != 1 {
+		t.Fatalf("Response answers = %d/%d, want 1/1", response.Header.ANCount, len(response.Answers))
+	}
+	if ip := net.IP(response.Answers[0].Data); !ip.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Answer = %v, want 192.168.1.1", ip)
+	}
+}
+
+func TestDNSServerSelfName(t *testing.T) {
+	testPort := 8101
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithBindAddr("127.0.0.1"), dns.WithSelfName("ns.self.test")))
+
+	response := exchange(t, testPort, buildQuery(t, 0xa102, "ns.self.test", dns.TYPE_A))
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %d, want 1", len(response.Answers))
+	}
+	if ip := net.IP(response.Answers[0].Data); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Answer = %v, want 127.0.0.1", ip)
+	}
+}
+
+func TestDNSServerBindAddrHTTPListeners(t *testing.T) {
+	testPort := 8108
+	healthPort := 8109
+	metricsPort := 8110
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithBindAddr("127.0.0.1"),
+		dns.WithHealthPort(healthPort), dns.WithMetricsPort(metricsPort)))
+
+	for _, port := range []int{healthPort, metricsPort} {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Port %d not listening on 127.0.0.1: %v", port, err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		// Another loopback address only reaches a listener on all interfaces
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.2", strconv.Itoa(port)), time.Second)
+		if err == nil {
+			conn.Close()
+			t.Errorf("Port %d accepted a connection on 127.0.0.2, want it bound to 127.0.0.1 only", port)
+		}
+	}
+}
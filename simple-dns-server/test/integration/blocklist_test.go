@@ -0,0 +1,74 @@
+package integration
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerBlocklistAddress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	contents := "# Ad servers\nwww.example.com\n\n*.ads.example.com\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Error writing blocklist: %v", err)
+	}
+	blocklist, err := dns.LoadBlocklist(path)
+	if err != nil {
+		t.Fatalf("LoadBlocklist() error = %v", err)
+	}
+
+	testPort := 8084
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithBlocklist(blocklist, dns.SINKHOLE_ADDRESS))
+	startTestServer(t, server)
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		want  net.IP
+	}{
+		{"www.example.com", dns.TYPE_A, net.IPv4zero},         // Exact block overrides the record store
+		{"tracker.ads.example.com", dns.TYPE_A, net.IPv4zero}, // Suffix block
+		{"tracker.ads.example.com", dns.TYPE_AAAA, net.IPv6zero},
+	}
+
+	for i, tt := range tests {
+		response := exchange(t, testPort, buildQuery(t, 0x9000+uint16(i), tt.name, tt.qtype))
+		if response.Header.ANCount != 1 || len(response.Answers) != 1 {
+			t.Errorf("%s type %d: answers = %d/%d, want 1/1", tt.name, tt.qtype, response.Header.ANCount, len(response.Answers))
+			continue
+		}
+		if ip := net.IP(response.Answers[0].Data); !ip.Equal(tt.want) {
+			t.Errorf("%s type %d: answer = %v, want %v", tt.name, tt.qtype, ip, tt.want)
+		}
+	}
+
+	// Names that aren't listed still resolve normally
+	response := exchange(t, testPort, buildQuery(t, 0x9010, "test.com", dns.TYPE_A))
+	if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data).Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("test.com answers = %+v, want 10.0.0.1", response.Answers)
+	}
+
+	if got := server.Metrics().Blocked.Load(); got != uint64(len(tests)) {
+		t.Errorf("Metrics().Blocked = %d, want %d", got, len(tests))
+	}
+}
+
+func TestDNSServerBlocklistNXDOMAIN(t *testing.T) {
+	blocklist := dns.NewBlocklist("www.example.com", "*.ads.example.com")
+
+	testPort := 8085
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithBlocklist(blocklist, dns.SINKHOLE_NXDOMAIN)))
+
+	for i, name := range []string{"www.example.com", "banner.ads.example.com"} {
+		response := exchange(t, testPort, buildQuery(t, 0x9020+uint16(i), name, dns.TYPE_A))
+		if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NXDOMAIN {
+			t.Errorf("%s: RCODE = %v, want %v (NXDOMAIN)", name, rcode, dns.RCODE_NXDOMAIN)
+		}
+		if response.Header.ANCount != 0 {
+			t.Errorf("%s: ANCount = %d, want 0", name, response.Header.ANCount)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerFlattenCNAME(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("cdn.example.com", dns.TYPE_CNAME, encodeName(t, "edge.example.com"))
+	store.AddRecord("edge.example.com", dns.TYPE_CNAME, encodeName(t, "www.example.com"))
+
+	testPort := 8060
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithRecordStore(store), dns.WithFlattenCNAME(true)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x7777, "cdn.example.com", dns.TYPE_A))
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+	}
+
+	answer := response.Answers[0]
+	if answer.Type != dns.TYPE_A {
+		t.Errorf("Answer.Type = %v, want %v", answer.Type, dns.TYPE_A)
+	}
+	if answer.Name != "cdn.example.com" {
+		t.Errorf("Answer.Name = %v, want %v", answer.Name, "cdn.example.com")
+	}
+	if !bytes.Equal(answer.Data, []byte{192, 168, 1, 1}) {
+		t.Errorf("Answer.Data = %v, want %v", answer.Data, []byte{192, 168, 1, 1})
+	}
+}
+
+func TestDNSServerCNAMEWithoutFlattening(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("cdn.example.com", dns.TYPE_CNAME, encodeName(t, "www.example.com"))
+
+	testPort := 8061
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x8888, "cdn.example.com", dns.TYPE_A))
+
+	if len(response.Answers) != 0 {
+		t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), 0)
+	}
+}
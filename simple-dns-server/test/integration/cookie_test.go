@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+// buildCookieQuery encodes a query whose OPT record carries cookie
+func buildCookieQuery(t *testing.T, id uint16, name string, cookie []byte) []byte {
+	t.Helper()
+
+	return encodeMessage(t, &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      id,
+			Flags:   0x0100,
+			QDCount: 1,
+			ARCount: 1,
+		},
+		Questions: []dns.DNSQuestion{
+			{Name: name, Type: dns.TYPE_A, Class: dns.CLASS_IN},
+		},
+		Additional: []dns.DNSResourceRecord{
+			{Name: "", Type: dns.TYPE_OPT, Class: 4096, Data: dns.EncodeCookieOption(cookie)},
+		},
+	})
+}
+
+func TestDNSServerCookies(t *testing.T) {
+	store := dns.NewRecordStore()
+	for i := 1; i <= 40; i++ {
+		store.AddRecord("big.example.com", dns.TYPE_A, []byte{10, 0, 2, byte(i)})
+	}
+
+	testPort := 8092
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithRecordStore(store), dns.WithCookies([]byte("test secret"), true)))
+
+	clientCookie := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	// Without a server cookie the large answer is truncated to 512 bytes
+	response := exchange(t, testPort, buildCookieQuery(t, 0x6201, "big.example.com", clientCookie))
+	if response.Header.Flags&0x0200 == 0 {
+		t.Errorf("Response should have TC flag set without a server cookie")
+	}
+
+	if len(response.Cookie) != dns.CLIENT_COOKIE_SIZE+dns.SERVER_COOKIE_SIZE {
+		t.Fatalf("Response cookie is %d bytes, want %d", len(response.Cookie), dns.CLIENT_COOKIE_SIZE+dns.SERVER_COOKIE_SIZE)
+	}
+	if !bytes.Equal(response.Cookie[:dns.CLIENT_COOKIE_SIZE], clientCookie) {
+		t.Errorf("Response client cookie = %x, want %x", response.Cookie[:dns.CLIENT_COOKIE_SIZE], clientCookie)
+	}
+
+	t.Run("valid_server_cookie", func(t *testing.T) {
+		response := exchange(t, testPort, buildCookieQuery(t, 0x6202, "big.example.com", response.Cookie))
+		if response.Header.Flags&0x0200 != 0 {
+			t.Errorf("Response should not have TC flag set with a valid server cookie")
+		}
+		if len(response.Answers) != 40 {
+			t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), 40)
+		}
+	})
+
+	t.Run("forged_server_cookie", func(t *testing.T) {
+		forged := append(bytes.Clone(clientCookie), make([]byte, dns.SERVER_COOKIE_SIZE)...)
+		response := exchange(t, testPort, buildCookieQuery(t, 0x6203, "big.example.com", forged))
+		if response.Header.Flags&0x0200 == 0 {
+			t.Errorf("Response should have TC flag set with a forged server cookie")
+		}
+	})
+
+	t.Run("malformed_cookie", func(t *testing.T) {
+		response := exchange(t, testPort, buildCookieQuery(t, 0x6204, "big.example.com", []byte{1, 2, 3}))
+		if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_FORMERR {
+			t.Errorf("Response RCODE = %v, want %v (FORMERR)", rcode, dns.RCODE_FORMERR)
+		}
+	})
+}
@@ -186,3 +186,80 @@ func TestDNSServerBasicQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestDNSServerZeroQuestions(t *testing.T) {
+	testPort := 8056
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	query := []byte{
+		0x9a, 0xbc, // ID
+		0x01, 0x00, // Flags (standard query)
+		0x00, 0x00, // QDCount (0 questions)
+		0x00, 0x00, // ANCount (0 answers)
+		0x00, 0x00, // NSCount (0 authority)
+		0x00, 0x00, // ARCount (0 additional)
+	}
+
+	responseMsg := exchange(t, testPort, query)
+
+	if responseMsg.Header.ID != 0x9abc {
+		t.Errorf("Response ID = %v, want %v", responseMsg.Header.ID, 0x9abc)
+	}
+
+	if responseMsg.Header.Flags&0x8000 == 0 {
+		t.Errorf("Response should have QR flag set (indicating response)")
+	}
+
+	if rcode := responseMsg.Header.Flags & 0x000F; rcode != dns.RCODE_FORMERR {
+		t.Errorf("Response RCODE = %v, want %v (FORMERR)", rcode, dns.RCODE_FORMERR)
+	}
+
+	if responseMsg.Header.QDCount != 0 || responseMsg.Header.ANCount != 0 {
+		t.Errorf("Response counts = %d/%d, want 0/0", responseMsg.Header.QDCount, responseMsg.Header.ANCount)
+	}
+}
+
+func TestDNSServerMultipleQuestions(t *testing.T) {
+	testPort := 8077
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	query := encodeMessage(t, &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      0x5a5a,
+			Flags:   0x0100, // Standard query with recursion desired
+			QDCount: 2,
+		},
+		Questions: []dns.DNSQuestion{
+			{Name: "www.example.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+			{Name: "test.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+		},
+	})
+
+	responseMsg := exchange(t, testPort, query)
+
+	if rcode := responseMsg.Header.Flags & 0x000F; rcode != dns.RCODE_NOERROR {
+		t.Errorf("Response RCODE = %v, want %v (NOERROR)", rcode, dns.RCODE_NOERROR)
+	}
+
+	if responseMsg.Header.QDCount != 2 || len(responseMsg.Questions) != 2 {
+		t.Fatalf("Response questions = %d (QDCount %d), want 2", len(responseMsg.Questions), responseMsg.Header.QDCount)
+	}
+	if responseMsg.Questions[0].Name != "www.example.com" || responseMsg.Questions[1].Name != "test.com" {
+		t.Errorf("Response questions = %q, %q, want www.example.com, test.com",
+			responseMsg.Questions[0].Name, responseMsg.Questions[1].Name)
+	}
+
+	if responseMsg.Header.ANCount != 2 || len(responseMsg.Answers) != 2 {
+		t.Fatalf("Response answers = %d (ANCount %d), want 2", len(responseMsg.Answers), responseMsg.Header.ANCount)
+	}
+
+	want := map[string]net.IP{
+		"www.example.com": net.IPv4(192, 168, 1, 1),
+		"test.com":        net.IPv4(10, 0, 0, 1),
+	}
+	for _, answer := range responseMsg.Answers {
+		if !net.IP(answer.Data).Equal(want[answer.Name]) {
+			t.Errorf("Answer for %s = %v, want %v", answer.Name, net.IP(answer.Data), want[answer.Name])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerDoH(t *testing.T) {
+	testPort := 8088
+	dohPort := 8089
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithDoH(dohPort, "", "")))
+
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d/dns-query", dohPort)
+	query := buildQuery(t, 0, "www.example.com", dns.TYPE_A)
+
+	t.Run("post", func(t *testing.T) {
+		resp, err := http.Post(endpoint, dns.DOH_CONTENT_TYPE, bytes.NewReader(query))
+		if err != nil {
+			t.Fatalf("Error posting query: %v", err)
+		}
+		checkDoHResponse(t, resp)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		resp, err := http.Get(endpoint + "?dns=" + base64.RawURLEncoding.EncodeToString(query))
+		if err != nil {
+			t.Fatalf("Error getting query: %v", err)
+		}
+		checkDoHResponse(t, resp)
+	})
+
+	t.Run("wrong_content_type", func(t *testing.T) {
+		resp, err := http.Post(endpoint, "text/plain", bytes.NewReader(query))
+		if err != nil {
+			t.Fatalf("Error posting query: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusUnsupportedMediaType)
+		}
+	})
+}
+
+// checkDoHResponse decodes a wire-format DoH response and checks it answers
+// www.example.com.
+func checkDoHResponse(t *testing.T, resp *http.Response) {
+	t.Helper()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != dns.DOH_CONTENT_TYPE {
+		t.Errorf("Content-Type = %q, want %q", contentType, dns.DOH_CONTENT_TYPE)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading response: %v", err)
+	}
+	response, err := dns.ParseDNSMessage(body)
+	if err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %d, want 1", len(response.Answers))
+	}
+	if ip := net.IP(response.Answers[0].Data); !ip.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Answer = %v, want 192.168.1.1", ip)
+	}
+}
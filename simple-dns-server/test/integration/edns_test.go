@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerEDNS(t *testing.T) {
+	store := dns.NewRecordStore()
+	for i := 1; i <= 40; i++ {
+		store.AddRecord("big.example.com", dns.TYPE_A, []byte{10, 0, 1, byte(i)})
+	}
+
+	testPort := 8066
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	t.Run("truncated_without_edns", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x4001, "big.example.com", dns.TYPE_A))
+
+		if response.Header.Flags&0x0200 == 0 {
+			t.Errorf("Response should have TC flag set")
+		}
+		if len(response.Answers) != 0 {
+			t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), 0)
+		}
+	})
+
+	t.Run("full_with_edns", func(t *testing.T) {
+		query := encodeMessage(t, &dns.DNSMessage{
+			Header: dns.DNSHeader{
+				ID:      0x4002,
+				Flags:   0x0100,
+				QDCount: 1,
+				ARCount: 1,
+			},
+			Questions: []dns.DNSQuestion{
+				{Name: "big.example.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+			},
+			Additional: []dns.DNSResourceRecord{
+				{Name: "", Type: dns.TYPE_OPT, Class: 4096},
+			},
+		})
+
+		response := exchange(t, testPort, query)
+
+		if response.Header.Flags&0x0200 != 0 {
+			t.Errorf("Response should not have TC flag set")
+		}
+		if len(response.Answers) != 40 {
+			t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), 40)
+		}
+		if response.UDPSize != dns.EDNS_UDP_SIZE {
+			t.Errorf("Response UDPSize = %v, want %v", response.UDPSize, dns.EDNS_UDP_SIZE)
+		}
+	})
+}
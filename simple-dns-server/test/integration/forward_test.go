@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerForwarderNegativeCache(t *testing.T) {
+	soa, err := dns.SOARecord{
+		MName: "ns1.upstream.test", RName: "admin.upstream.test",
+		Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 60,
+	}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upstreamQueries atomic.Int32
+	upstream := startFakeResponder(t, func(query *dns.DNSMessage) []*dns.DNSMessage {
+		upstreamQueries.Add(1)
+		if query.Questions[0].Name == "found.upstream.test" {
+			return []*dns.DNSMessage{answerA(query, query.Header.ID, net.IPv4(192, 0, 2, 53))}
+		}
+		return []*dns.DNSMessage{{
+			Header: dns.DNSHeader{
+				ID:      query.Header.ID,
+				Flags:   0x8183, // NXDOMAIN
+				QDCount: 1,
+				NSCount: 1,
+			},
+			Questions: query.Questions,
+			Authority: []dns.DNSResourceRecord{
+				{Name: "upstream.test", Type: dns.TYPE_SOA, Class: dns.CLASS_IN, TTL: 300, Data: soa},
+			},
+		}}
+	})
+
+	testPort := 8100
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithForwarder(upstream)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6301, "found.upstream.test", dns.TYPE_A))
+	if !response.Header.RA() {
+		t.Errorf("Response RA = false, want true with a forwarder")
+	}
+	if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data).Equal(net.IPv4(192, 0, 2, 53)) {
+		t.Fatalf("Answers = %v, want the upstream's 192.0.2.53", response.Answers)
+	}
+
+	// Local records are still answered without the upstream
+	before := upstreamQueries.Load()
+	exchange(t, testPort, buildQuery(t, 0x6302, "www.example.com", dns.TYPE_A))
+	if upstreamQueries.Load() != before {
+		t.Errorf("Local name was forwarded upstream")
+	}
+
+	before = upstreamQueries.Load()
+	for i, id := range []uint16{0x6303, 0x6304} {
+		response := exchange(t, testPort, buildQuery(t, id, "missing.upstream.test", dns.TYPE_A))
+		if rcode := response.Header.RCODE(); rcode != dns.RCODE_NXDOMAIN {
+			t.Errorf("Query %d RCODE = %v, want %v (NXDOMAIN)", i+1, rcode, dns.RCODE_NXDOMAIN)
+		}
+		if len(response.Authority) != 1 || response.Authority[0].Type != dns.TYPE_SOA {
+			t.Fatalf("Query %d authority = %v, want the upstream SOA", i+1, response.Authority)
+		}
+		// The negative TTL is the SOA minimum, not the SOA record's TTL
+		if ttl := response.Authority[0].TTL; ttl > 60 {
+			t.Errorf("Query %d SOA TTL = %d, want at most 60", i+1, ttl)
+		}
+	}
+	if got := upstreamQueries.Load() - before; got != 1 {
+		t.Errorf("Upstream received %d queries for the missing name, want 1", got)
+	}
+}
+
+// startRawResponder starts a fake upstream on a random UDP port that answers
+// each query with the bytes respond builds from it, for responses the encoder
+// would not produce, and returns its address.
+func startRawResponder(t *testing.T, respond func(query []byte) []byte) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buffer := make([]byte, dns.EDNS_UDP_SIZE)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(respond(buffer[:n]), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestDNSServerForwarderCompressedRDATA(t *testing.T) {
+	var upstreamQueries atomic.Int32
+	upstream := startRawResponder(t, func(query []byte) []byte {
+		upstreamQueries.Add(1)
+		question, err := dns.ParseDNSMessage(query)
+		if err != nil {
+			return nil
+		}
+		// Echo the header and question; the question name starts at offset 12
+		name, _ := dns.EncodeDomainName(question.Questions[0].Name)
+		msg := append([]byte(nil), query[:12+len(name)+4]...)
+		msg[2], msg[3] = 0x81, 0x80
+		msg[10], msg[11] = 0, 0 // No additional records
+
+		if question.Questions[0].Name == "www.upstream.test" {
+			// "upstream.test" starts 4 bytes into the question name
+			msg[7] = 2 // Two answers
+			msg = append(msg, 0xC0, 12, 0, dns.TYPE_CNAME, 0, 1, 0, 0, 1, 44, 0, 6)
+			target := len(msg)
+			msg = append(msg, 3, 'w', 'e', 'b', 0xC0, 16)
+			msg = append(msg, 0xC0, byte(target), 0, dns.TYPE_A, 0, 1, 0, 0, 1, 44, 0, 4, 192, 0, 2, 80)
+			return msg
+		}
+
+		// NXDOMAIN with an SOA whose names point into the question
+		msg[3] = 0x83
+		msg[9] = 1 // One authority record
+		zone := byte(12 + 1 + len("missing"))
+		msg = append(msg, 0xC0, zone, 0, dns.TYPE_SOA, 0, 1, 0, 0, 1, 44, 0, 34)
+		msg = append(msg, 3, 'n', 's', '1', 0xC0, zone)
+		msg = append(msg, 5, 'a', 'd', 'm', 'i', 'n', 0xC0, zone)
+		for _, timer := range []uint32{1, 3600, 600, 86400, 60} {
+			msg = binary.BigEndian.AppendUint32(msg, timer)
+		}
+		return msg
+	})
+
+	testPort := 8107
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithForwarder(upstream)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6401, "www.upstream.test", dns.TYPE_A))
+	if len(response.Answers) != 2 {
+		t.Fatalf("Answers = %v, want the upstream's CNAME and A", response.Answers)
+	}
+	target, err := dns.EncodeDomainName("web.upstream.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname := response.Answers[0]; cname.Type != dns.TYPE_CNAME || !bytes.Equal(cname.Data, target) {
+		t.Errorf("CNAME data = %v, want web.upstream.test written out", cname.Data)
+	}
+	if a := response.Answers[1]; a.Name != "web.upstream.test" || !net.IP(a.Data).Equal(net.IPv4(192, 0, 2, 80)) {
+		t.Errorf("A record = %s %v, want web.upstream.test 192.0.2.80", a.Name, a.Data)
+	}
+
+	before := upstreamQueries.Load()
+	for i, id := range []uint16{0x6402, 0x6403} {
+		response := exchange(t, testPort, buildQuery(t, id, "missing.upstream.test", dns.TYPE_A))
+		if len(response.Authority) != 1 {
+			t.Fatalf("Query %d authority = %v, want the upstream SOA", i+1, response.Authority)
+		}
+		soa, err := dns.ParseSOARecord(response.Authority[0].Data)
+		if err != nil {
+			t.Fatalf("Query %d SOA does not parse: %v", i+1, err)
+		}
+		if soa.MName != "ns1.upstream.test" || soa.RName != "admin.upstream.test" {
+			t.Errorf("Query %d SOA names = %s %s, want them written out", i+1, soa.MName, soa.RName)
+		}
+	}
+	// A parseable SOA lets the negative answer be cached
+	if got := upstreamQueries.Load() - before; got != 1 {
+		t.Errorf("Upstream received %d queries for the missing name, want 1", got)
+	}
+}
@@ -0,0 +1,51 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerQueryHandler(t *testing.T) {
+	testPort := 8055
+	server := dns.NewServer(testPort, newTestLogger())
+
+	computedIP := []byte{10, 10, 10, 10}
+	server.SetQueryHandler(func(q dns.DNSQuestion) ([]dns.DNSResourceRecord, bool) {
+		if q.Name != "www.example.com" || q.Type != dns.TYPE_A {
+			return nil, false
+		}
+		return []dns.DNSResourceRecord{
+			{Name: q.Name, Type: dns.TYPE_A, Class: dns.CLASS_IN, TTL: 60, Data: computedIP},
+		}, true
+	})
+
+	startTestServer(t, server)
+
+	t.Run("handler_wins_over_store", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x1111, "www.example.com", dns.TYPE_A))
+
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		if !bytes.Equal(response.Answers[0].Data, computedIP) {
+			t.Errorf("Answer.Data = %v, want %v", response.Answers[0].Data, computedIP)
+		}
+		if response.Answers[0].TTL != 60 {
+			t.Errorf("Answer.TTL = %v, want %v", response.Answers[0].TTL, 60)
+		}
+	})
+
+	t.Run("falls_through_to_store", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x2222, "test.com", dns.TYPE_A))
+
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		expectedIP := []byte{10, 0, 0, 1}
+		if !bytes.Equal(response.Answers[0].Data, expectedIP) {
+			t.Errorf("Answer.Data = %v, want %v", response.Answers[0].Data, expectedIP)
+		}
+	})
+}
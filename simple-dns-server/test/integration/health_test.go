@@ -0,0 +1,40 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerHealthName(t *testing.T) {
+	testPort := 8058
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithHealthName(true), dns.WithTTLBounds(60, 0)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x5555, dns.HEALTH_NAME, dns.TYPE_A))
+
+	if response.Header.Flags&0x000F != dns.RCODE_NOERROR {
+		t.Errorf("Response RCODE = %v, want %v", response.Header.Flags&0x000F, dns.RCODE_NOERROR)
+	}
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+	}
+	if !bytes.Equal(response.Answers[0].Data, []byte{127, 0, 0, 1}) {
+		t.Errorf("Answer.Data = %v, want %v", response.Answers[0].Data, []byte{127, 0, 0, 1})
+	}
+	if response.Answers[0].TTL != 0 {
+		t.Errorf("Answer.TTL = %v, want %v", response.Answers[0].TTL, 0)
+	}
+}
+
+func TestDNSServerHealthNameDisabled(t *testing.T) {
+	testPort := 8059
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6666, dns.HEALTH_NAME, dns.TYPE_A))
+
+	if len(response.Answers) != 0 {
+		t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), 0)
+	}
+}
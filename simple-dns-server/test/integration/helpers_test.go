@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+// newTestLogger returns a logger that only reports warnings and above.
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelWarn, // Reduce noise during tests
+	}))
+}
+
+// startTestServer starts the server in the background, waits until /readyz
+// reports its listeners are serving, and stops it when the test finishes.
+func startTestServer(t testing.TB, server *dns.Server) {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Start()
+	}()
+
+	t.Cleanup(func() {
+		server.Stop()
+	})
+
+	health := server.HealthHandler()
+	deadline := time.After(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code == http.StatusOK {
+			return
+		}
+
+		select {
+		case err := <-errs:
+			t.Fatalf("Server failed to start: %v", err)
+		case <-deadline:
+			t.Fatal("Server did not become ready")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// buildQuery encodes a standard query with a single question.
+func buildQuery(t testing.TB, id uint16, name string, qtype uint16) []byte {
+	t.Helper()
+
+	return encodeMessage(t, &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      id,
+			Flags:   0x0100, // Standard query with recursion desired
+			QDCount: 1,
+		},
+		Questions: []dns.DNSQuestion{
+			{Name: name, Type: qtype, Class: dns.CLASS_IN},
+		},
+	})
+}
+
+// encodeMessage encodes msg, failing the test on error.
+func encodeMessage(t testing.TB, msg *dns.DNSMessage) []byte {
+	t.Helper()
+
+	encoded, err := dns.EncodeDNSMessage(msg)
+	if err != nil {
+		t.Fatalf("Error encoding message: %v", err)
+	}
+	return encoded
+}
+
+// encodeName encodes a domain name, failing the test on error.
+func encodeName(t *testing.T, name string) []byte {
+	t.Helper()
+
+	encoded, err := dns.EncodeDomainName(name)
+	if err != nil {
+		t.Fatalf("Error encoding domain name %q: %v", name, err)
+	}
+	return encoded
+}
+
+// exchange sends a raw query to the server on the given port and parses the
+// response.
+func exchange(t *testing.T, port int, query []byte) *dns.DNSMessage {
+	t.Helper()
+
+	serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("Error resolving server address: %v", err)
+	}
+
+	clientConn, err := net.DialUDP("udp", nil, serverAddr)
+	if err != nil {
+		t.Fatalf("Error connecting to server: %v", err)
+	}
+	defer clientConn.Close()
+
+	if _, err := clientConn.Write(query); err != nil {
+		t.Fatalf("Error sending query: %v", err)
+	}
+
+	response := make([]byte, dns.EDNS_UDP_SIZE)
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := clientConn.Read(response)
+	if err != nil {
+		t.Fatalf("Error reading response: %v", err)
+	}
+
+	responseMsg, err := dns.ParseDNSMessage(response[:n])
+	if err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+
+	return responseMsg
+}
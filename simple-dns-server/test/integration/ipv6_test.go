@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerIPv6(t *testing.T) {
+	if conn, err := net.ListenPacket("udp6", "[::1]:0"); err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	testPort := 8099
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(),
+		dns.WithAddressFamily(dns.FAMILY_IPV6), dns.WithBindAddr("::1")))
+
+	conn, err := net.Dial("udp6", fmt.Sprintf("[::1]:%d", testPort))
+	if err != nil {
+		t.Fatalf("Error connecting to server: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(buildQuery(t, 0xa201, "localhost", dns.TYPE_AAAA)); err != nil {
+		t.Fatalf("Error sending query: %v", err)
+	}
+
+	buffer := make([]byte, dns.EDNS_UDP_SIZE)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("Error reading response: %v", err)
+	}
+
+	response, err := dns.ParseDNSMessage(buffer[:n])
+	if err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data).Equal(net.IPv6loopback) {
+		t.Errorf("Answers = %v, want ::1", response.Answers)
+	}
+
+	// The IPv6-only server must not be reachable over IPv4
+	t.Run("no_ipv4", func(t *testing.T) {
+		conn, err := net.Dial("udp4", fmt.Sprintf("127.0.0.1:%d", testPort))
+		if err != nil {
+			t.Fatalf("Error connecting: %v", err)
+		}
+		defer conn.Close()
+
+		conn.Write(buildQuery(t, 0xa202, "localhost", dns.TYPE_A))
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := conn.Read(buffer); err == nil {
+			t.Errorf("Got a response over IPv4 from an IPv6-only server")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerMetricsEndpoint(t *testing.T) {
+	testPort := 8074
+	metricsPort := 8075
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithMetricsPort(metricsPort))
+	startTestServer(t, server)
+
+	exchange(t, testPort, buildQuery(t, 0xb001, "www.example.com", dns.TYPE_A))
+	exchange(t, testPort, buildQuery(t, 0xb002, "test.com", dns.TYPE_A))
+	exchange(t, testPort, buildQuery(t, 0xb003, "missing.example.org", dns.TYPE_AAAA))
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", metricsPort))
+	if err != nil {
+		t.Fatalf("Error scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		"dns_queries_total 3",
+		"dns_answered_total 2",
+		"dns_nxdomain_total 1",
+		"dns_parse_errors_total 0",
+		`dns_queries_by_type_total{type="A"} 2`,
+		`dns_queries_by_type_total{type="AAAA"} 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("Metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestDNSServerMetricsDisabledByDefault(t *testing.T) {
+	testPort := 8076
+	server := dns.NewServer(testPort, newTestLogger())
+	startTestServer(t, server)
+
+	exchange(t, testPort, buildQuery(t, 0xb004, "www.example.com", dns.TYPE_A))
+
+	if got := server.Metrics().Queries.Load(); got != 1 {
+		t.Errorf("Metrics().Queries = %d, want 1", got)
+	}
+}
+
+func TestDNSServerLatencyHistogram(t *testing.T) {
+	testPort := 8095
+	metricsPort := 8096
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithMetricsPort(metricsPort))
+	startTestServer(t, server)
+
+	const queries = 5
+	for i := range queries {
+		exchange(t, testPort, buildQuery(t, 0xb010+uint16(i), "www.example.com", dns.TYPE_A))
+	}
+
+	// Latency is recorded just after the response is written
+	deadline := time.Now().Add(time.Second)
+	for server.Metrics().LatencyCount() < queries && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := server.Metrics().LatencyCount(); got != queries {
+		t.Fatalf("Metrics().LatencyCount() = %d, want %d", got, queries)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", metricsPort))
+	if err != nil {
+		t.Fatalf("Error scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		"# TYPE dns_query_duration_seconds histogram",
+		fmt.Sprintf(`dns_query_duration_seconds_bucket{le="+Inf"} %d`, queries),
+		fmt.Sprintf("dns_query_duration_seconds_count %d", queries),
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("Metrics missing %q:\n%s", want, body)
+		}
+	}
+}
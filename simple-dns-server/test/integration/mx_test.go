@@ -0,0 +1,42 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerMXQuery(t *testing.T) {
+	mxData, err := dns.MXRecord{Preference: 10, Exchange: "mail.example.com"}.Encode()
+	if err != nil {
+		t.Fatalf("MXRecord.Encode() error = %v", err)
+	}
+
+	store := dns.NewRecordStore()
+	store.AddRecord("example.com", dns.TYPE_MX, mxData)
+
+	testPort := 8067
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x5001, "example.com", dns.TYPE_MX))
+
+	if response.Header.ANCount != 1 || len(response.Answers) != 1 {
+		t.Fatalf("Response answers = %d/%d, want 1/1", response.Header.ANCount, len(response.Answers))
+	}
+
+	answer := response.Answers[0]
+	if answer.Type != dns.TYPE_MX {
+		t.Errorf("Answer.Type = %v, want %v", answer.Type, dns.TYPE_MX)
+	}
+
+	mx, err := dns.ParseMXRecord(answer.Data)
+	if err != nil {
+		t.Fatalf("ParseMXRecord() error = %v", err)
+	}
+	if mx.Preference != 10 {
+		t.Errorf("MX.Preference = %v, want %v", mx.Preference, 10)
+	}
+	if mx.Exchange != "mail.example.com" {
+		t.Errorf("MX.Exchange = %v, want %v", mx.Exchange, "mail.example.com")
+	}
+}
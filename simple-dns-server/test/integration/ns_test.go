@@ -0,0 +1,47 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerNSQuery(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("example.com", dns.TYPE_NS, encodeName(t, "ns1.example.com"))
+
+	testPort := 8068
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	t.Run("ns_in_answer", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x6001, "example.com", dns.TYPE_NS))
+
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		if response.Answers[0].Type != dns.TYPE_NS {
+			t.Errorf("Answer.Type = %v, want %v", response.Answers[0].Type, dns.TYPE_NS)
+		}
+		if !bytes.Equal(response.Answers[0].Data, encodeName(t, "ns1.example.com")) {
+			t.Errorf("Answer.Data = %v, want ns1.example.com", response.Answers[0].Data)
+		}
+	})
+
+	t.Run("ns_in_authority", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x6002, "www.example.com", dns.TYPE_A))
+
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		if response.Header.NSCount != 1 {
+			t.Errorf("Response NSCount = %v, want %v", response.Header.NSCount, 1)
+		}
+		if len(response.Authority) != 1 {
+			t.Fatalf("len(Response.Authority) = %v, want %v", len(response.Authority), 1)
+		}
+		if response.Authority[0].Name != "example.com" || response.Authority[0].Type != dns.TYPE_NS {
+			t.Errorf("Authority = %+v, want NS for example.com", response.Authority[0])
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerReverseLookup(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("1.1.168.192.in-addr.arpa", dns.TYPE_PTR, encodeName(t, "www.example.com"))
+
+	testPort := 8069
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x7001, "1.1.168.192.in-addr.arpa", dns.TYPE_PTR))
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+	}
+	if response.Answers[0].Type != dns.TYPE_PTR {
+		t.Errorf("Answer.Type = %v, want %v", response.Answers[0].Type, dns.TYPE_PTR)
+	}
+	if !bytes.Equal(response.Answers[0].Data, encodeName(t, "www.example.com")) {
+		t.Errorf("Answer.Data = %v, want www.example.com", response.Answers[0].Data)
+	}
+}
@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerQueryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	queryLog, err := dns.NewQueryLog(path, 0)
+	if err != nil {
+		t.Fatalf("NewQueryLog() error = %v", err)
+	}
+	t.Cleanup(func() { queryLog.Close() })
+
+	testPort := 8083
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithQueryLog(queryLog)))
+
+	exchange(t, testPort, buildQuery(t, 0x8001, "www.example.com", dns.TYPE_A))
+	exchange(t, testPort, buildQuery(t, 0x8002, "missing.example.org", dns.TYPE_AAAA))
+
+	entries := waitForQueryLog(t, path, 2)
+	if len(entries) != 2 {
+		t.Fatalf("Query log has %d entries, want 2", len(entries))
+	}
+
+	want := []struct {
+		name  string
+		qtype string
+		rcode uint16
+	}{
+		{"www.example.com", "A", dns.RCODE_NOERROR},
+		{"missing.example.org", "AAAA", dns.RCODE_NXDOMAIN},
+	}
+	for i, w := range want {
+		entry := entries[i]
+		if entry.Name != w.name || entry.Type != w.qtype || entry.RCODE != w.rcode {
+			t.Errorf("Entry %d = %s %s rcode %d, want %s %s rcode %d",
+				i, entry.Name, entry.Type, entry.RCODE, w.name, w.qtype, w.rcode)
+		}
+		if entry.ClientIP != "127.0.0.1" {
+			t.Errorf("Entry %d ClientIP = %q, want 127.0.0.1", i, entry.ClientIP)
+		}
+		if entry.Time.IsZero() {
+			t.Errorf("Entry %d has no timestamp", i)
+		}
+	}
+}
+
+func TestDNSServerQueryLogErrorResponses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	queryLog, err := dns.NewQueryLog(path, 0)
+	if err != nil {
+		t.Fatalf("NewQueryLog() error = %v", err)
+	}
+	t.Cleanup(func() { queryLog.Close() })
+
+	loopback, err := dns.ParseCIDRs("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	elsewhere, err := dns.ParseCIDRs("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPort := 8105
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithQueryLog(queryLog),
+		dns.WithAllowedNetworks(loopback...),
+		dns.WithSOA("example.com", dns.SOARecord{MName: "ns1.example.com", RName: "admin.example.com", Minimum: 60})))
+	refusingPort := 8106
+	startTestServer(t, dns.NewServer(refusingPort, newTestLogger(), dns.WithQueryLog(queryLog),
+		dns.WithAllowedNetworks(elsewhere...)))
+
+	// A header promising a question that isn't there
+	exchange(t, testPort, []byte{0x80, 0x01, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0})
+	waitForQueryLog(t, path, 1)
+	exchange(t, testPort, buildQuery(t, 0x8002, "example.com", dns.TYPE_AXFR)) // Over UDP
+	waitForQueryLog(t, path, 2)
+	exchange(t, refusingPort, buildQuery(t, 0x8003, "www.example.com", dns.TYPE_A))
+
+	entries := waitForQueryLog(t, path, 3)
+	if len(entries) != 3 {
+		t.Fatalf("Query log has %d entries, want 3", len(entries))
+	}
+
+	want := []struct {
+		name  string
+		qtype string
+		rcode uint16
+	}{
+		{"", "", dns.RCODE_FORMERR},
+		{"example.com", "AXFR", dns.RCODE_REFUSED},
+		{"", "", dns.RCODE_REFUSED}, // Refused before the question is parsed
+	}
+	for i, w := range want {
+		entry := entries[i]
+		if entry.Name != w.name || entry.Type != w.qtype || entry.RCODE != w.rcode {
+			t.Errorf("Entry %d = %q %q rcode %d, want %q %q rcode %d",
+				i, entry.Name, entry.Type, entry.RCODE, w.name, w.qtype, w.rcode)
+		}
+	}
+}
+
+// waitForQueryLog reads the query log at path until it has n entries or a
+// second passes. Entries are written just after the response is sent.
+func waitForQueryLog(t *testing.T, path string, n int) []dns.QueryLogEntry {
+	t.Helper()
+
+	var entries []dns.QueryLogEntry
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		entries = readQueryLog(t, path)
+		if len(entries) >= n {
+			break
+		}
+	}
+	return entries
+}
+
+// readQueryLog parses every JSON line in the query log at path.
+func readQueryLog(t *testing.T, path string) []dns.QueryLogEntry {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening query log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []dns.QueryLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry dns.QueryLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid query log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
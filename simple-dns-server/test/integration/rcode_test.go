@@ -0,0 +1,165 @@
+package integration
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerRCODE(t *testing.T) {
+	testPort := 8064
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	tests := []struct {
+		name        string
+		query       []byte
+		wantRCODE   uint16
+		wantAnswers int
+	}{
+		{
+			name:        "noerror_with_answer",
+			query:       buildQuery(t, 0x3001, "example.com", dns.TYPE_A),
+			wantRCODE:   dns.RCODE_NOERROR,
+			wantAnswers: 1,
+		},
+		{
+			name:        "noerror_nodata",
+			query:       buildQuery(t, 0x3002, "example.com", dns.TYPE_AAAA),
+			wantRCODE:   dns.RCODE_NOERROR,
+			wantAnswers: 0,
+		},
+		{
+			name:        "nxdomain",
+			query:       buildQuery(t, 0x3003, "missing.example.com", dns.TYPE_A),
+			wantRCODE:   dns.RCODE_NXDOMAIN,
+			wantAnswers: 0,
+		},
+		{
+			name: "formerr_on_truncated_question",
+			query: []byte{
+				0x30, 0x04, // ID
+				0x01, 0x00, // Flags (standard query)
+				0x00, 0x01, // QDCount (1 question)
+				0x00, 0x00, // ANCount (0 answers)
+				0x00, 0x00, // NSCount (0 authority)
+				0x00, 0x00, // ARCount (0 additional)
+				7, 'e', 'x', 'a', // Label cut short
+			},
+			wantRCODE:   dns.RCODE_FORMERR,
+			wantAnswers: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := exchange(t, testPort, tt.query)
+
+			if wantID := uint16(tt.query[0])<<8 | uint16(tt.query[1]); response.Header.ID != wantID {
+				t.Errorf("Response ID = %#04x, want %#04x", response.Header.ID, wantID)
+			}
+			if response.Header.Flags&0x8000 == 0 {
+				t.Errorf("Response should have QR flag set (indicating response)")
+			}
+			if rcode := response.Header.Flags & 0x000F; rcode != tt.wantRCODE {
+				t.Errorf("Response RCODE = %v, want %v", rcode, tt.wantRCODE)
+			}
+			if len(response.Answers) != tt.wantAnswers {
+				t.Errorf("len(Response.Answers) = %v, want %v", len(response.Answers), tt.wantAnswers)
+			}
+		})
+	}
+}
+
+func TestDNSServerDropsMalformedResponse(t *testing.T) {
+	testPort := 8111
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", testPort))
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+	defer conn.Close()
+
+	malformed := []byte{
+		0x30, 0x05, // ID
+		0x81, 0x00, // Flags (response)
+		0x00, 0x01, // QDCount (1 question)
+		0x00, 0x00, // ANCount (0 answers)
+		0x00, 0x00, // NSCount (0 authority)
+		0x00, 0x00, // ARCount (0 additional)
+		7, 'e', 'x', 'a', // Label cut short
+	}
+	if _, err := conn.Write(malformed); err != nil {
+		t.Fatalf("Error sending: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	buffer := make([]byte, dns.MESSAGE_SIZE)
+	if n, err := conn.Read(buffer); err == nil {
+		t.Fatalf("Got a %d byte reply to a malformed response, want none", n)
+	}
+
+	// The server is still answering queries
+	exchange(t, testPort, buildQuery(t, 0x3006, "example.com", dns.TYPE_A))
+}
+
+func TestDNSServerNotImplementedOpcode(t *testing.T) {
+	testPort := 8065
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	query := encodeMessage(t, &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      0x3101,
+			Flags:   dns.OPCODE_STATUS << 11,
+			QDCount: 1,
+		},
+		Questions: []dns.DNSQuestion{
+			{Name: "www.example.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+		},
+	})
+
+	response := exchange(t, testPort, query)
+
+	if response.Header.ID != 0x3101 {
+		t.Errorf("Response ID = %v, want %v", response.Header.ID, 0x3101)
+	}
+	if response.Header.Flags&0x8000 == 0 {
+		t.Errorf("Response should have QR flag set (indicating response)")
+	}
+	if opcode := (response.Header.Flags >> 11) & 0x000F; opcode != dns.OPCODE_STATUS {
+		t.Errorf("Response opcode = %v, want %v", opcode, dns.OPCODE_STATUS)
+	}
+	if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NOTIMP {
+		t.Errorf("Response RCODE = %v, want %v", rcode, dns.RCODE_NOTIMP)
+	}
+	if response.Header.ANCount != 0 || len(response.Answers) != 0 {
+		t.Errorf("NOTIMP response should have no answers, got %v", len(response.Answers))
+	}
+}
+
+func TestDNSServerRecursionFlags(t *testing.T) {
+	testPort := 8098
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	for _, rd := range []bool{false, true} {
+		header := dns.DNSHeader{ID: 0x3201, QDCount: 1}
+		header.SetRD(rd)
+		query := encodeMessage(t, &dns.DNSMessage{
+			Header: header,
+			Questions: []dns.DNSQuestion{
+				{Name: "www.example.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+			},
+		})
+
+		response := exchange(t, testPort, query)
+		if response.Header.RD() != rd {
+			t.Errorf("Response RD = %v for query RD = %v, want it echoed", response.Header.RD(), rd)
+		}
+		// Without forwarding the server cannot recurse
+		if response.Header.RA() {
+			t.Errorf("Response RA = true for query RD = %v, want false", rd)
+		}
+	}
+}
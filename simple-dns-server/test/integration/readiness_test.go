@@ -0,0 +1,44 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerReadiness(t *testing.T) {
+	testPort := 8093
+	healthPort := 8094
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithHealthPort(healthPort))
+
+	// Before Start the listeners are not bound
+	recorder := httptest.NewRecorder()
+	server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz before start = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+
+	startTestServer(t, server)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", healthPort, path))
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s after start = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	server.Stop()
+
+	recorder = httptest.NewRecorder()
+	server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz after stop = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerReloadRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	store := dns.NewRecordStore()
+	store.AddRecord("reload.example.com", dns.TYPE_A, []byte{192, 0, 2, 20})
+	if err := store.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	testPort := 8091
+	server := dns.NewServer(testPort, newTestLogger())
+	startTestServer(t, server)
+
+	if err := server.ReloadRecords(path); err != nil {
+		t.Fatalf("ReloadRecords() error = %v", err)
+	}
+	response := exchange(t, testPort, buildQuery(t, 0x6101, "reload.example.com", dns.TYPE_A))
+	if len(response.Answers) != 1 || !bytes.Equal(response.Answers[0].Data, []byte{192, 0, 2, 20}) {
+		t.Fatalf("Answers = %v, want 192.0.2.20", response.Answers)
+	}
+
+	// Change the file and reload again
+	store.RemoveRecord("reload.example.com", dns.TYPE_A)
+	store.AddRecord("reload.example.com", dns.TYPE_A, []byte{192, 0, 2, 21})
+	if err := store.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+	if err := server.ReloadRecords(path); err != nil {
+		t.Fatalf("ReloadRecords() error = %v", err)
+	}
+	response = exchange(t, testPort, buildQuery(t, 0x6102, "reload.example.com", dns.TYPE_A))
+	if len(response.Answers) != 1 || !bytes.Equal(response.Answers[0].Data, []byte{192, 0, 2, 21}) {
+		t.Fatalf("Answers = %v, want 192.0.2.21", response.Answers)
+	}
+
+	t.Run("parse_error_keeps_records", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("{broken"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.ReloadRecords(path); err == nil {
+			t.Fatalf("ReloadRecords() error = nil, want parse error")
+		}
+
+		response := exchange(t, testPort, buildQuery(t, 0x6103, "reload.example.com", dns.TYPE_A))
+		if len(response.Answers) != 1 || !bytes.Equal(response.Answers[0].Data, []byte{192, 0, 2, 21}) {
+			t.Errorf("Answers = %v, want previous record 192.0.2.21", response.Answers)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestResolverLookup(t *testing.T) {
+	testPort := 8078
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	resolver := dns.NewResolver(fmt.Sprintf("127.0.0.1:%d", testPort))
+
+	answers, err := resolver.Lookup("www.example.com", dns.TYPE_A)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("Lookup() returned %d answers, want 1", len(answers))
+	}
+	if ip := net.IP(answers[0].Data); !ip.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Lookup() answer = %v, want 192.168.1.1", ip)
+	}
+
+	if _, err := resolver.Lookup("nonexistent.example.org", dns.TYPE_A); err == nil {
+		t.Errorf("Lookup() of a missing name expected an error")
+	}
+}
+
+func TestNewResolverDefaultPort(t *testing.T) {
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"192.0.2.53", "192.0.2.53:53"},
+		{"2001:db8::53", "[2001:db8::53]:53"},
+		{"192.0.2.53:5353", "192.0.2.53:5353"},
+	}
+
+	for _, tt := range tests {
+		if got := dns.NewResolver(tt.server).Server(); got != tt.want {
+			t.Errorf("NewResolver(%q).Server() = %q, want %q", tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestResolverLookupTimeout(t *testing.T) {
+	// A bound socket that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer conn.Close()
+
+	resolver := dns.NewResolver(conn.LocalAddr().String(), dns.WithResolverTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	if _, err := resolver.Lookup("www.example.com", dns.TYPE_A); err == nil {
+		t.Fatalf("Lookup() expected a timeout error")
+	}
+	// One retry means two full timeouts
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Lookup() gave up after %v, want a retry after the first timeout", elapsed)
+	}
+}
+
+// startFakeResponder answers every query on a local UDP socket with the
+// messages returned by respond, in order, and returns the socket address.
+func startFakeResponder(t *testing.T, respond func(query *dns.DNSMessage) []*dns.DNSMessage) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buffer := make([]byte, dns.EDNS_UDP_SIZE)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			query, err := dns.ParseDNSMessage(buffer[:n])
+			if err != nil {
+				continue
+			}
+			for _, msg := range respond(query) {
+				encoded, err := dns.EncodeDNSMessage(msg)
+				if err != nil {
+					continue
+				}
+				conn.WriteTo(encoded, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// answerA builds a response to query with a single A record for ip.
+func answerA(query *dns.DNSMessage, id uint16, ip net.IP) *dns.DNSMessage {
+	return &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      id,
+			Flags:   0x8180,
+			QDCount: 1,
+			ANCount: 1,
+		},
+		Questions: query.Questions,
+		Answers: []dns.DNSResourceRecord{
+			{Name: query.Questions[0].Name, Type: dns.TYPE_A, Class: dns.CLASS_IN, TTL: 60, Data: ip.To4()},
+		},
+	}
+}
+
+func TestResolverRejectsMismatchedID(t *testing.T) {
+	addr := startFakeResponder(t, func(query *dns.DNSMessage) []*dns.DNSMessage {
+		return []*dns.DNSMessage{answerA(query, query.Header.ID+1, net.IPv4(6, 6, 6, 6))}
+	})
+
+	resolver := dns.NewResolver(addr, dns.WithResolverTimeout(200*time.Millisecond))
+	if answers, err := resolver.Lookup("www.example.com", dns.TYPE_A); err == nil {
+		t.Errorf("Lookup() = %v, want error for a response with the wrong ID", answers)
+	}
+}
+
+func TestResolverSkipsSpoofedResponse(t *testing.T) {
+	addr := startFakeResponder(t, func(query *dns.DNSMessage) []*dns.DNSMessage {
+		return []*dns.DNSMessage{
+			answerA(query, query.Header.ID+1, net.IPv4(6, 6, 6, 6)), // Spoofed
+			answerA(query, query.Header.ID, net.IPv4(192, 168, 1, 1)),
+		}
+	})
+
+	resolver := dns.NewResolver(addr, dns.WithResolverTimeout(time.Second))
+	answers, err := resolver.Lookup("www.example.com", dns.TYPE_A)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("Lookup() returned %d answers, want 1", len(answers))
+	}
+	if ip := net.IP(answers[0].Data); !ip.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Lookup() answer = %v, want 192.168.1.1 from the matching response", ip)
+	}
+}
@@ -0,0 +1,34 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerRoundRobin(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("pool.example.com", dns.TYPE_A, []byte{10, 0, 0, 1})
+	store.AddRecord("pool.example.com", dns.TYPE_A, []byte{10, 0, 0, 2})
+	store.AddRecord("pool.example.com", dns.TYPE_A, []byte{10, 0, 0, 3})
+
+	testPort := 8062
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	first := exchange(t, testPort, buildQuery(t, 0x1001, "pool.example.com", dns.TYPE_A))
+	second := exchange(t, testPort, buildQuery(t, 0x1002, "pool.example.com", dns.TYPE_A))
+
+	for _, response := range []*dns.DNSMessage{first, second} {
+		if response.Header.ANCount != 3 {
+			t.Errorf("Response ANCount = %v, want %v", response.Header.ANCount, 3)
+		}
+		if len(response.Answers) != 3 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 3)
+		}
+	}
+
+	if bytes.Equal(first.Answers[0].Data, second.Answers[0].Data) {
+		t.Errorf("First answer did not rotate between queries: %v", first.Answers[0].Data)
+	}
+}
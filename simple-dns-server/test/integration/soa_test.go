@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+var testSOA = dns.SOARecord{
+	MName:   "ns1.example.com",
+	RName:   "hostmaster.example.com",
+	Serial:  1,
+	Refresh: 7200,
+	Retry:   3600,
+	Expire:  1209600,
+	Minimum: 60,
+}
+
+func TestDNSServerSOAOnNXDOMAIN(t *testing.T) {
+	testPort := 8079
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithSOA("example.com", testSOA)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6001, "missing.example.com", dns.TYPE_A))
+
+	if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NXDOMAIN {
+		t.Errorf("Response RCODE = %v, want %v (NXDOMAIN)", rcode, dns.RCODE_NXDOMAIN)
+	}
+	if response.Header.NSCount != 1 || len(response.Authority) != 1 {
+		t.Fatalf("Response authority = %d/%d, want 1/1", response.Header.NSCount, len(response.Authority))
+	}
+
+	authority := response.Authority[0]
+	if authority.Type != dns.TYPE_SOA || authority.Name != "example.com" {
+		t.Errorf("Authority = %s type %d, want example.com SOA", authority.Name, authority.Type)
+	}
+	if authority.TTL != testSOA.Minimum {
+		t.Errorf("Authority TTL = %d, want SOA minimum %d", authority.TTL, testSOA.Minimum)
+	}
+
+	soa, err := dns.ParseSOARecord(authority.Data)
+	if err != nil {
+		t.Fatalf("ParseSOARecord() error = %v", err)
+	}
+	if soa != testSOA {
+		t.Errorf("SOA = %+v, want %+v", soa, testSOA)
+	}
+}
+
+func TestDNSServerSOAOnNODATA(t *testing.T) {
+	testPort := 8080
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithSOA("example.com", testSOA)))
+
+	// www.example.com has an A record but no AAAA
+	response := exchange(t, testPort, buildQuery(t, 0x6002, "www.example.com", dns.TYPE_AAAA))
+
+	if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NOERROR {
+		t.Errorf("Response RCODE = %v, want %v (NOERROR)", rcode, dns.RCODE_NOERROR)
+	}
+	if response.Header.NSCount != 1 || len(response.Authority) != 1 || response.Authority[0].Type != dns.TYPE_SOA {
+		t.Errorf("Response authority = %+v, want the zone SOA", response.Authority)
+	}
+
+	// Names outside the zone get no SOA
+	response = exchange(t, testPort, buildQuery(t, 0x6003, "missing.example.org", dns.TYPE_A))
+	if response.Header.NSCount != 0 {
+		t.Errorf("Response NSCount = %d for a name outside the zone, want 0", response.Header.NSCount)
+	}
+}
+
+func TestDNSServerSOAQuery(t *testing.T) {
+	testPort := 8081
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithSOA("example.com", testSOA)))
+
+	response := exchange(t, testPort, buildQuery(t, 0x6004, "example.com", dns.TYPE_SOA))
+
+	if response.Header.ANCount != 1 || len(response.Answers) != 1 {
+		t.Fatalf("Response answers = %d/%d, want 1/1", response.Header.ANCount, len(response.Answers))
+	}
+	if response.Answers[0].Type != dns.TYPE_SOA {
+		t.Errorf("Answer.Type = %v, want %v", response.Answers[0].Type, dns.TYPE_SOA)
+	}
+}
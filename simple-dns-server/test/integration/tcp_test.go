@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerTCPQuery(t *testing.T) {
+	testPort := 8063
+	startTestServer(t, dns.NewServer(testPort, newTestLogger()))
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", testPort))
+	if err != nil {
+		t.Fatalf("Error connecting to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// Two queries on the same connection, each with its own length prefix
+	for _, id := range []uint16{0x2001, 0x2002} {
+		query := buildQuery(t, id, "www.example.com", dns.TYPE_A)
+		frame := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(frame, query...)); err != nil {
+			t.Fatalf("Error sending query: %v", err)
+		}
+
+		var lengthPrefix [2]byte
+		if _, err := io.ReadFull(conn, lengthPrefix[:]); err != nil {
+			t.Fatalf("Error reading length prefix: %v", err)
+		}
+		response := make([]byte, binary.BigEndian.Uint16(lengthPrefix[:]))
+		if _, err := io.ReadFull(conn, response); err != nil {
+			t.Fatalf("Error reading response: %v", err)
+		}
+
+		responseMsg, err := dns.ParseDNSMessage(response)
+		if err != nil {
+			t.Fatalf("Error parsing response: %v", err)
+		}
+
+		if responseMsg.Header.ID != id {
+			t.Errorf("Response ID = %v, want %v", responseMsg.Header.ID, id)
+		}
+		if len(responseMsg.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(responseMsg.Answers), 1)
+		}
+		if !bytes.Equal(responseMsg.Answers[0].Data, []byte{192, 168, 1, 1}) {
+			t.Errorf("Answer.Data = %v, want %v", responseMsg.Answers[0].Data, []byte{192, 168, 1, 1})
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerTTLBounds(t *testing.T) {
+	testPort := 8057
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithTTLBounds(600, 3600))
+	server.SetQueryHandler(func(q dns.DNSQuestion) ([]dns.DNSResourceRecord, bool) {
+		if q.Name != "long.example.com" {
+			return nil, false
+		}
+		return []dns.DNSResourceRecord{
+			{Name: q.Name, Type: dns.TYPE_A, Class: dns.CLASS_IN, TTL: 86400, Data: []byte{10, 0, 0, 2}},
+		}, true
+	})
+	startTestServer(t, server)
+
+	t.Run("clamped_to_max", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x3333, "long.example.com", dns.TYPE_A))
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		if response.Answers[0].TTL != 3600 {
+			t.Errorf("Answer.TTL = %v, want %v", response.Answers[0].TTL, 3600)
+		}
+	})
+
+	t.Run("raised_to_min", func(t *testing.T) {
+		response := exchange(t, testPort, buildQuery(t, 0x4444, "www.example.com", dns.TYPE_A))
+		if len(response.Answers) != 1 {
+			t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+		}
+		if response.Answers[0].TTL != 600 {
+			t.Errorf("Answer.TTL = %v, want %v", response.Answers[0].TTL, 600)
+		}
+	})
+}
+
+func TestDNSServerPerRecordTTL(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecordTTL("dynamic.example.com", dns.TYPE_A, []byte{10, 0, 0, 60}, 60)
+
+	testPort := 8087
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	tests := []struct {
+		name string
+		want uint32
+	}{
+		{"dynamic.example.com", 60},
+		{"www.example.com", dns.DEFAULT_TTL}, // Records added without a TTL keep the default
+	}
+
+	for i, tt := range tests {
+		response := exchange(t, testPort, buildQuery(t, 0x4500+uint16(i), tt.name, dns.TYPE_A))
+		if len(response.Answers) != 1 {
+			t.Fatalf("%s: len(Response.Answers) = %v, want %v", tt.name, len(response.Answers), 1)
+		}
+		if response.Answers[0].TTL != tt.want {
+			t.Errorf("%s: Answer.TTL = %v, want %v", tt.name, response.Answers[0].TTL, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerVersionBind(t *testing.T) {
+	testPort := 8070
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithVersion("dns-server 1.0")))
+
+	query := encodeMessage(t, &dns.DNSMessage{
+		Header: dns.DNSHeader{
+			ID:      0x8001,
+			QDCount: 1,
+		},
+		Questions: []dns.DNSQuestion{
+			{Name: dns.VERSION_NAME, Type: dns.TYPE_TXT, Class: dns.CLASS_CH},
+		},
+	})
+
+	response := exchange(t, testPort, query)
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("len(Response.Answers) = %v, want %v", len(response.Answers), 1)
+	}
+
+	answer := response.Answers[0]
+	if answer.Type != dns.TYPE_TXT || answer.Class != dns.CLASS_CH {
+		t.Errorf("Answer type/class = %v/%v, want %v/%v", answer.Type, answer.Class, dns.TYPE_TXT, dns.CLASS_CH)
+	}
+
+	version, err := dns.ParseTXTRecord(answer.Data)
+	if err != nil {
+		t.Fatalf("ParseTXTRecord() error = %v", err)
+	}
+	if version != "dns-server 1.0" {
+		t.Errorf("version = %q, want %q", version, "dns-server 1.0")
+	}
+}
@@ -0,0 +1,46 @@
+package integration
+
+import (
+	"net"
+	"testing"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerWildcard(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("*.example.com", dns.TYPE_A, []byte{192, 168, 1, 50})
+
+	testPort := 8082
+	startTestServer(t, dns.NewServer(testPort, newTestLogger(), dns.WithRecordStore(store)))
+
+	tests := []struct {
+		domain string
+		want   net.IP
+	}{
+		{"foo.example.com", net.IPv4(192, 168, 1, 50)},
+		{"www.example.com", net.IPv4(192, 168, 1, 1)},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			response := exchange(t, testPort, buildQuery(t, 0x7000+uint16(i), tt.domain, dns.TYPE_A))
+
+			if response.Header.ANCount != 1 || len(response.Answers) != 1 {
+				t.Fatalf("Response answers = %d/%d, want 1/1", response.Header.ANCount, len(response.Answers))
+			}
+			if response.Answers[0].Name != tt.domain {
+				t.Errorf("Answer.Name = %q, want the queried name %q", response.Answers[0].Name, tt.domain)
+			}
+			if ip := net.IP(response.Answers[0].Data); !ip.Equal(tt.want) {
+				t.Errorf("Answer = %v, want %v", ip, tt.want)
+			}
+		})
+	}
+
+	// A wildcard-covered name without the asked type is NODATA, not NXDOMAIN
+	response := exchange(t, testPort, buildQuery(t, 0x7010, "foo.example.com", dns.TYPE_AAAA))
+	if rcode := response.Header.Flags & 0x000F; rcode != dns.RCODE_NOERROR {
+		t.Errorf("Response RCODE = %v, want %v (NOERROR)", rcode, dns.RCODE_NOERROR)
+	}
+}
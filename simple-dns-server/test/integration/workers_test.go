@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"dns-server/internal/dns"
+)
+
+func TestDNSServerWorkerPoolBoundsGoroutines(t *testing.T) {
+	testPort := 8073
+	server := dns.NewServer(testPort, newTestLogger(), dns.WithWorkerPool(2, 4))
+
+	// Keep workers busy so the queue fills up during the flood
+	server.SetQueryHandler(func(q dns.DNSQuestion) ([]dns.DNSResourceRecord, bool) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, false
+	})
+	startTestServer(t, server)
+
+	baseline := runtime.NumGoroutine()
+
+	clientConn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", testPort))
+	if err != nil {
+		t.Fatalf("Error connecting to server: %v", err)
+	}
+	defer clientConn.Close()
+
+	query := buildQuery(t, 0xa001, "www.example.com", dns.TYPE_A)
+	peak := baseline
+	for i := 0; i < 500; i++ {
+		if _, err := clientConn.Write(query); err != nil {
+			t.Fatalf("Error sending query: %v", err)
+		}
+		peak = max(peak, runtime.NumGoroutine())
+	}
+
+	// Give the reader a moment to drain the socket
+	time.Sleep(200 * time.Millisecond)
+	peak = max(peak, runtime.NumGoroutine())
+
+	if peak > baseline+5 {
+		t.Errorf("Goroutines grew from %d to %d during flood, want bounded", baseline, peak)
+	}
+	if server.DroppedPackets() == 0 {
+		t.Errorf("DroppedPackets() = 0, want packets dropped once the queue is full")
+	}
+}
+
+// BenchmarkUDPQuery measures a full UDP round trip; allocations include the
+// server's, since they share the process
+func BenchmarkUDPQuery(b *testing.B) {
+	testPort := 8097
+	startTestServer(b, dns.NewServer(testPort, newTestLogger()))
+
+	conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", testPort))
+	if err != nil {
+		b.Fatalf("Error connecting to server: %v", err)
+	}
+	defer conn.Close()
+
+	query := buildQuery(b, 0xd001, "www.example.com", dns.TYPE_A)
+	response := make([]byte, dns.EDNS_UDP_SIZE)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.Write(query); err != nil {
+			b.Fatalf("Error sending query: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := conn.Read(response); err != nil {
+			b.Fatalf("Error reading response: %v", err)
+		}
+	}
+}
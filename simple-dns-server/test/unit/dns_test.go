@@ -2,7 +2,15 @@ package unit
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"dns-server/internal/dns"
 )
@@ -28,11 +36,24 @@ func TestEncodeDomainName(t *testing.T) {
 			input:    "localhost",
 			expected: []byte{9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0},
 		},
+		{
+			name:     "trailing dot",
+			input:    "example.com.",
+			expected: []byte{7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0},
+		},
+		{
+			name:     "root",
+			input:    ".",
+			expected: []byte{0},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := dns.EncodeDomainName(tt.input)
+			result, err := dns.EncodeDomainName(tt.input)
+			if err != nil {
+				t.Fatalf("EncodeDomainName() error = %v", err)
+			}
 			if !bytes.Equal(result, tt.expected) {
 				t.Errorf("EncodeDomainName() = %v, want %v", result, tt.expected)
 			}
@@ -40,6 +61,26 @@ func TestEncodeDomainName(t *testing.T) {
 	}
 }
 
+func TestEncodeDomainNameInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "interior empty label", input: "foo..bar"},
+		{name: "leading dot", input: ".example.com"},
+		{name: "double trailing dot", input: "example.com.."},
+		{name: "label too long", input: strings.Repeat("a", 64) + ".com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result, err := dns.EncodeDomainName(tt.input); err == nil {
+				t.Errorf("EncodeDomainName() = %v, expected error", result)
+			}
+		})
+	}
+}
+
 func TestParseDNSMessage(t *testing.T) {
 	// Create a simple DNS query for www.example.com A record
 	data := []byte{
@@ -104,6 +145,80 @@ func TestParseDNSMessageTooShort(t *testing.T) {
 	}
 }
 
+func TestParseDNSMessageQuestionCount(t *testing.T) {
+	question := []byte{
+		3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, // Type A
+		0x00, 0x01, // Class IN
+	}
+	header := func(qdCount uint16) []byte {
+		return []byte{0x12, 0x34, 0x01, 0x00, byte(qdCount >> 8), byte(qdCount), 0, 0, 0, 0, 0, 0}
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"inflated_count", append(header(50), question...), "declares 50 questions"},
+		{"above_maximum", append(header(dns.MAX_QUESTIONS+1), make([]byte, 1000)...), "exceeds maximum"},
+		{"trailing_bytes", append(append(header(1), question...), 0xde, 0xad), "2 bytes after"},
+		{"second_question_cut_short", append(append(header(2), question...), 3, 'w', 'w', 'w', 0), "question 2 of 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dns.ParseDNSMessage(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseDNSMessage() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := dns.ParseDNSMessage(append(header(1), question...)); err != nil {
+		t.Errorf("ParseDNSMessage() of a well-formed message error = %v", err)
+	}
+}
+
+func TestParseDNSMessageSentinelErrors(t *testing.T) {
+	header := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0} // One question
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"too_short", []byte{0x12, 0x34, 0x01}, dns.ErrMessageTooShort},
+		{"label_cut_short", append(bytes.Clone(header), 7, 'e', 'x', 'a', 0, 0), dns.ErrUnexpectedEnd},
+		{"missing_type_and_class", append(bytes.Clone(header), 3, 'c', 'o', 'm', 0), dns.ErrUnexpectedEnd},
+		{"pointer_truncated", []byte{0x12, 0x34, 0x81, 0x80, 0, 0, 0, 1, 0, 0, 0, 0, 0xC0}, dns.ErrInvalidPointer}, // One answer
+		{"pointer_loop", append(bytes.Clone(header), 0xC0, 12, 0, 1, 0, 1), dns.ErrInvalidPointer},
+		{"label_then_pointer_loop", append(bytes.Clone(header), 1, 'a', 0xC0, 12, 0, 1, 0, 1), dns.ErrInvalidPointer},
+		{"label_too_long", append(append(bytes.Clone(header), 64), make([]byte, 68)...), dns.ErrLabelOverflow},
+		{"too_many_questions", []byte{0x12, 0x34, 0x01, 0x00, 0x01, 0x00, 0, 0, 0, 0, 0, 0}, dns.ErrTooManyQuestions},
+		{"trailing_data", append(bytes.Clone(header), 0, 0, 1, 0, 1, 0xFF), dns.ErrTrailingData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := dns.ParseDNSMessage(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("ParseDNSMessage() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A name longer than 255 bytes overflows even though every label fits
+	name := bytes.Clone(header)
+	for range 5 {
+		name = append(name, 63)
+		name = append(name, bytes.Repeat([]byte{'a'}, 63)...)
+	}
+	name = append(name, 0, 0, 1, 0, 1)
+	if _, err := dns.ParseDNSMessage(name); !errors.Is(err, dns.ErrLabelOverflow) {
+		t.Errorf("ParseDNSMessage() of a 321-byte name error = %v, want %v", err, dns.ErrLabelOverflow)
+	}
+}
+
 func TestEncodeDNSMessage(t *testing.T) {
 	msg := &dns.DNSMessage{
 		Header: dns.DNSHeader{
@@ -132,7 +247,10 @@ func TestEncodeDNSMessage(t *testing.T) {
 		},
 	}
 
-	encoded := dns.EncodeDNSMessage(msg)
+	encoded, err := dns.EncodeDNSMessage(msg)
+	if err != nil {
+		t.Fatalf("EncodeDNSMessage() error = %v", err)
+	}
 
 	// Check header encoding
 	if encoded[0] != 0x12 || encoded[1] != 0x34 {
@@ -162,6 +280,34 @@ func TestEncodeDNSMessage(t *testing.T) {
 	}
 }
 
+func TestEncodeDNSMessageInvalidRecordData(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType uint16
+		data       []byte
+	}{
+		{"A with 6 bytes", dns.TYPE_A, []byte{10, 0, 0, 1, 0, 0}},
+		{"A with 3 bytes", dns.TYPE_A, []byte{10, 0, 0}},
+		{"AAAA with 4 bytes", dns.TYPE_AAAA, []byte{10, 0, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &dns.DNSMessage{
+				Header: dns.DNSHeader{ID: 0x1234, Flags: 0x8180, ANCount: 1},
+				Answers: []dns.DNSResourceRecord{
+					{Name: "test.com", Type: tt.recordType, Class: dns.CLASS_IN, TTL: 300, Data: tt.data},
+				},
+			}
+
+			encoded, err := dns.EncodeDNSMessage(msg)
+			if err == nil {
+				t.Errorf("EncodeDNSMessage() = %x, want error", encoded)
+			}
+		})
+	}
+}
+
 func TestRecordStore(t *testing.T) {
 	store := dns.NewRecordStore()
 
@@ -218,7 +364,10 @@ func TestDNSMessageRoundTrip(t *testing.T) {
 	}
 
 	// Encode the message
-	encoded := dns.EncodeDNSMessage(originalMsg)
+	encoded, err := dns.EncodeDNSMessage(originalMsg)
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
 
 	// Parse it back
 	parsed, err := dns.ParseDNSMessage(encoded)
@@ -252,7 +401,7 @@ func BenchmarkEncodeDomainName(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = dns.EncodeDomainName(domain)
+		_, _ = dns.EncodeDomainName(domain)
 	}
 }
 
@@ -305,6 +454,713 @@ func BenchmarkEncodeDNSMessage(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = dns.EncodeDNSMessage(msg)
+		_, _ = dns.EncodeDNSMessage(msg)
+	}
+}
+
+func BenchmarkEncodeDNSMessageManyAnswers(b *testing.B) {
+	msg := &dns.DNSMessage{
+		Header: dns.DNSHeader{ID: 0x1234, Flags: 0x8180, QDCount: 1, ANCount: 16},
+		Questions: []dns.DNSQuestion{
+			{Name: "pool.example.com", Type: dns.TYPE_A, Class: dns.CLASS_IN},
+		},
+	}
+	for i := range 16 {
+		msg.Answers = append(msg.Answers, dns.DNSResourceRecord{
+			Name:  "pool.example.com",
+			Type:  dns.TYPE_A,
+			Class: dns.CLASS_IN,
+			TTL:   300,
+			Data:  []byte{10, 0, 0, byte(i)},
+		})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = dns.EncodeDNSMessage(msg)
+	}
+}
+
+func TestRecordStoreMultipleRecords(t *testing.T) {
+	store := dns.NewRecordStore()
+
+	ips := [][]byte{{10, 0, 0, 1}, {10, 0, 0, 2}, {10, 0, 0, 3}}
+	for _, ip := range ips {
+		store.AddRecord("pool.example.com", dns.TYPE_A, ip)
+	}
+	// Adding a duplicate must not create a fourth record
+	store.AddRecord("pool.example.com", dns.TYPE_A, ips[0])
+
+	first, _, found := store.LookupRecords("pool.example.com", dns.TYPE_A)
+	if !found {
+		t.Fatalf("Expected to find records for pool.example.com")
+	}
+	if len(first) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(first))
+	}
+
+	second, _, _ := store.LookupRecords("pool.example.com", dns.TYPE_A)
+	if bytes.Equal(first[0], second[0]) {
+		t.Errorf("Expected first record to rotate, got %v twice", first[0])
+	}
+	if !bytes.Equal(first[1], second[0]) {
+		t.Errorf("Expected second lookup to start with %v, got %v", first[1], second[0])
+	}
+}
+
+func TestRecordStoreTTL(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecordTTL("dynamic.example.com", dns.TYPE_A, []byte{10, 0, 0, 1}, 60)
+
+	if _, ttl, found := store.LookupRecords("dynamic.example.com", dns.TYPE_A); !found || ttl != 60 {
+		t.Errorf("LookupRecords() TTL = %d (found %v), want 60", ttl, found)
+	}
+	if _, ttl, _ := store.LookupRecords("www.example.com", dns.TYPE_A); ttl != dns.DEFAULT_TTL {
+		t.Errorf("LookupRecords() TTL = %d for a default record, want %d", ttl, dns.DEFAULT_TTL)
+	}
+
+	// The record set shares one TTL, so a later TTL applies to every record
+	store.AddRecordTTL("dynamic.example.com", dns.TYPE_A, []byte{10, 0, 0, 2}, 30)
+	records, ttl, _ := store.LookupRecords("dynamic.example.com", dns.TYPE_A)
+	if len(records) != 2 || ttl != 30 {
+		t.Errorf("LookupRecords() = %d records with TTL %d, want 2 with TTL 30", len(records), ttl)
+	}
+}
+
+func TestRecordStoreLookupAll(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("example.com", dns.TYPE_TXT, dns.EncodeTXTRecord("hello"))
+	store.AddRecord("example.com", dns.TYPE_A, []byte{192, 0, 2, 1})
+
+	all := store.LookupAll("EXAMPLE.com")
+	if len(all) != 2 {
+		t.Fatalf("LookupAll() returned %d types, want 2", len(all))
+	}
+	if len(all[dns.TYPE_A]) != 2 {
+		t.Errorf("LookupAll() A records = %d, want 2", len(all[dns.TYPE_A]))
+	}
+	if len(all[dns.TYPE_TXT]) != 1 {
+		t.Errorf("LookupAll() TXT records = %d, want 1", len(all[dns.TYPE_TXT]))
+	}
+
+	if all := store.LookupAll("nonexistent.com"); len(all) != 0 {
+		t.Errorf("LookupAll(nonexistent.com) = %v, want empty", all)
+	}
+}
+
+func TestRecordStoreLocalhostDualStack(t *testing.T) {
+	store := dns.NewRecordStore()
+
+	if data, found := store.LookupRecord("localhost", dns.TYPE_A); !found || !bytes.Equal(data, []byte{127, 0, 0, 1}) {
+		t.Errorf("LookupRecord(localhost, A) = %v (found %v), want 127.0.0.1", data, found)
+	}
+	if data, found := store.LookupRecord("localhost", dns.TYPE_AAAA); !found || !net.IP(data).Equal(net.IPv6loopback) {
+		t.Errorf("LookupRecord(localhost, AAAA) = %v (found %v), want ::1", data, found)
+	}
+}
+
+func TestRecordStoreSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	store := dns.NewRecordStore()
+	store.AddRecordTTL("saved.example.com", dns.TYPE_A, []byte{10, 0, 0, 7}, 120)
+	if err := store.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	fresh := dns.NewRecordStore()
+	if fresh.HasName("saved.example.com") {
+		t.Fatalf("fresh store already has saved.example.com")
+	}
+	if err := fresh.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	records, ttl, found := fresh.LookupRecords("saved.example.com", dns.TYPE_A)
+	if !found || len(records) != 1 || !bytes.Equal(records[0], []byte{10, 0, 0, 7}) {
+		t.Fatalf("LookupRecords() = %v (found %v), want [10.0.0.7]", records, found)
+	}
+	if ttl != 120 {
+		t.Errorf("LookupRecords() TTL = %d, want 120", ttl)
+	}
+	if _, _, found := fresh.LookupRecords("www.example.com", dns.TYPE_A); !found {
+		t.Errorf("Expected default records to be loaded from the file")
+	}
+}
+
+func TestRecordStoreLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := dns.NewRecordStore()
+	if err := store.LoadFromFile(path); err == nil {
+		t.Fatalf("LoadFromFile() error = nil, want parse error")
+	}
+	if !store.HasName("example.com") {
+		t.Errorf("Expected a failed load to leave the store unchanged")
+	}
+}
+
+func TestRecordStoreLoadLowercasesNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	// 10.0.0.1 and 10.0.0.2, under names differing only in case
+	data := `{
+		"Mixed.Example.COM": [{"type": 1, "ttl": 60, "data": ["CgAAAQ=="]}],
+		"mixed.example.com": [{"type": 1, "ttl": 60, "data": ["CgAAAg=="]}]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := dns.NewRecordStore()
+	if err := store.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	records, _, found := store.LookupRecords("MIXED.example.com", dns.TYPE_A)
+	if !found {
+		t.Fatalf("Expected a mixed-case name from the file to be found")
+	}
+	if len(records) != 2 {
+		t.Errorf("Got %d records, want both names' addresses merged", len(records))
+	}
+}
+
+func TestRecordStoreAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	store := dns.NewRecordStore(dns.WithAutoSave(path))
+	store.AddRecord("auto.example.com", dns.TYPE_A, []byte{10, 0, 0, 8})
+
+	fresh := dns.NewRecordStore()
+	if err := fresh.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if !fresh.HasName("auto.example.com") {
+		t.Fatalf("Expected auto-saved record to be present")
+	}
+
+	store.RemoveRecord("auto.example.com", dns.TYPE_A)
+	if err := fresh.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if fresh.HasName("auto.example.com") {
+		t.Errorf("Expected removal to be auto-saved")
+	}
+}
+
+func TestRecordStoreAutoSaveSkipsUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	store := dns.NewRecordStore(dns.WithAutoSave(path))
+	store.AddRecord("auto.example.com", dns.TYPE_A, []byte{10, 0, 0, 8})
+
+	// With the file gone, only a save brings it back
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	store.AddRecord("auto.example.com", dns.TYPE_A, []byte{10, 0, 0, 8})
+	store.RemoveRecord("auto.example.com", dns.TYPE_AAAA)
+	store.RemoveRecord("missing.example.com", dns.TYPE_A)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Records file rewritten though nothing changed (Stat error = %v)", err)
+	}
+
+	store.AddRecordTTL("auto.example.com", dns.TYPE_A, []byte{10, 0, 0, 8}, 60)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Records file not saved after a TTL change: %v", err)
+	}
+}
+
+func TestRecordStoreAutoSaveConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	store := dns.NewRecordStore(dns.WithAutoSave(path))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.AddRecord(fmt.Sprintf("host%d.example.com", i), dns.TYPE_A, []byte{10, 0, 1, byte(i)})
+		}()
+	}
+	wg.Wait()
+
+	fresh := dns.NewRecordStore()
+	if err := fresh.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if name := fmt.Sprintf("host%d.example.com", i); !fresh.HasName(name) {
+			t.Errorf("Saved records are missing %s", name)
+		}
+	}
+}
+
+func TestRecordStoreHasName(t *testing.T) {
+	store := dns.NewRecordStore()
+
+	if !store.HasName("example.com") {
+		t.Errorf("Expected example.com to exist")
+	}
+	if store.HasName("nonexistent.com") {
+		t.Errorf("Expected nonexistent.com not to exist")
+	}
+
+	store.RemoveRecord("example.com", dns.TYPE_A)
+	if store.HasName("example.com") {
+		t.Errorf("Expected example.com to be gone after removing its only record")
+	}
+}
+
+func TestRecordStoreCaseInsensitive(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("WWW.Mixed.COM", dns.TYPE_A, []byte{10, 1, 2, 3})
+
+	for _, name := range []string{"WWW.Mixed.COM", "www.mixed.com", "Www.MIXED.com"} {
+		data, found := store.LookupRecord(name, dns.TYPE_A)
+		if !found {
+			t.Errorf("LookupRecord(%q) found no record", name)
+			continue
+		}
+		if !bytes.Equal(data, []byte{10, 1, 2, 3}) {
+			t.Errorf("LookupRecord(%q) = %v, want %v", name, data, []byte{10, 1, 2, 3})
+		}
+		if !store.HasName(name) {
+			t.Errorf("HasName(%q) = false, want true", name)
+		}
+	}
+
+	store.RemoveRecord("www.MIXED.com", dns.TYPE_A)
+	if store.HasName("www.mixed.com") {
+		t.Errorf("Expected record to be removed regardless of case")
+	}
+}
+
+func TestRecordStoreWildcard(t *testing.T) {
+	store := dns.NewRecordStore()
+	store.AddRecord("*.example.com", dns.TYPE_A, []byte{192, 168, 1, 50})
+
+	tests := []struct {
+		domain string
+		want   []byte
+	}{
+		{"foo.example.com", []byte{192, 168, 1, 50}}, // Matches the wildcard
+		{"a.b.example.com", []byte{192, 168, 1, 50}}, // Wildcards cover deeper names
+		{"www.example.com", []byte{192, 168, 1, 1}},  // An exact match takes precedence
+		{"example.com", []byte{192, 168, 1, 1}},      // The apex is not covered
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			data, found := store.LookupRecord(tt.domain, dns.TYPE_A)
+			if !found {
+				t.Fatalf("LookupRecord(%q) found no record", tt.domain)
+			}
+			if !bytes.Equal(data, tt.want) {
+				t.Errorf("LookupRecord(%q) = %v, want %v", tt.domain, data, tt.want)
+			}
+		})
+	}
+
+	if _, found := store.LookupRecord("foo.test.com", dns.TYPE_A); found {
+		t.Errorf("LookupRecord(foo.test.com) matched a wildcard from another zone")
+	}
+	if store.HasName("foo.test.com") {
+		t.Errorf("HasName(foo.test.com) = true, want false")
+	}
+}
+
+func TestParseDNSMessageEDNS(t *testing.T) {
+	data := []byte{
+		0x12, 0x34, // ID
+		0x01, 0x00, // Flags (standard query)
+		0x00, 0x01, // QDCount (1 question)
+		0x00, 0x00, // ANCount (0 answers)
+		0x00, 0x00, // NSCount (0 authority)
+		0x00, 0x01, // ARCount (1 additional)
+		// Question section
+		3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, // www.example.com
+		0x00, 0x01, // Type A
+		0x00, 0x01, // Class IN
+		// Additional section: OPT pseudo-record
+		0,          // Root name
+		0x00, 0x29, // Type OPT (41)
+		0x10, 0x00, // UDP payload size 4096
+		0x00, 0x00, 0x00, 0x00, // Extended RCODE, version, flags
+		0x00, 0x00, // RDLENGTH
+	}
+
+	msg, err := dns.ParseDNSMessage(data)
+	if err != nil {
+		t.Fatalf("ParseDNSMessage() error = %v", err)
+	}
+
+	if msg.UDPSize != 4096 {
+		t.Errorf("UDPSize = %v, want %v", msg.UDPSize, 4096)
+	}
+
+	if len(msg.Additional) != 1 || msg.Additional[0].Type != dns.TYPE_OPT {
+		t.Errorf("Additional = %+v, want one OPT record", msg.Additional)
+	}
+}
+
+func TestMXRecordRoundTrip(t *testing.T) {
+	mx := dns.MXRecord{Preference: 10, Exchange: "mail.example.com"}
+	data, err := mx.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// 2 bytes of preference plus the encoded exchange name
+	exchange, _ := dns.EncodeDomainName("mail.example.com")
+	if want := 2 + len(exchange); len(data) != want {
+		t.Errorf("len(Encode()) = %v, want %v", len(data), want)
+	}
+
+	parsed, err := dns.ParseMXRecord(data)
+	if err != nil {
+		t.Fatalf("ParseMXRecord() error = %v", err)
+	}
+	if parsed != mx {
+		t.Errorf("ParseMXRecord() = %+v, want %+v", parsed, mx)
+	}
+}
+
+func TestSOARecordRoundTrip(t *testing.T) {
+	soa := dns.SOARecord{
+		MName:   "ns1.example.com",
+		RName:   "hostmaster.example.com",
+		Serial:  2024010101,
+		Refresh: 7200,
+		Retry:   3600,
+		Expire:  1209600,
+		Minimum: 300,
+	}
+	data, err := soa.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Both encoded names plus five 32-bit timers
+	mname, _ := dns.EncodeDomainName(soa.MName)
+	rname, _ := dns.EncodeDomainName(soa.RName)
+	if want := len(mname) + len(rname) + 20; len(data) != want {
+		t.Errorf("len(Encode()) = %v, want %v", len(data), want)
+	}
+
+	parsed, err := dns.ParseSOARecord(data)
+	if err != nil {
+		t.Fatalf("ParseSOARecord() error = %v", err)
+	}
+	if parsed != soa {
+		t.Errorf("ParseSOARecord() = %+v, want %+v", parsed, soa)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"192.168.1.1", "1.1.168.192.in-addr.arpa"},
+		{"10.0.0.1", "1.0.0.10.in-addr.arpa"},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := dns.ReverseName(net.ParseIP(tt.ip)); got != tt.expected {
+				t.Errorf("ReverseName() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRecordStoreAddPTRForA(t *testing.T) {
+	store := dns.NewRecordStore()
+	if err := store.AddPTRForA("host.example.com", []byte{192, 168, 1, 20}); err != nil {
+		t.Fatalf("AddPTRForA() error = %v", err)
+	}
+
+	if _, found := store.LookupRecord("host.example.com", dns.TYPE_A); !found {
+		t.Errorf("Expected A record for host.example.com")
+	}
+
+	data, found := store.LookupRecord("20.1.168.192.in-addr.arpa", dns.TYPE_PTR)
+	if !found {
+		t.Fatalf("Expected PTR record for 20.1.168.192.in-addr.arpa")
+	}
+	if target, _ := dns.EncodeDomainName("host.example.com"); !bytes.Equal(data, target) {
+		t.Errorf("PTR data = %v, want host.example.com", data)
+	}
+}
+
+func TestTXTRecordRoundTrip(t *testing.T) {
+	tests := []string{"", "dns-server 1.0", strings.Repeat("x", 300)}
+
+	for _, text := range tests {
+		data := dns.EncodeTXTRecord(text)
+		parsed, err := dns.ParseTXTRecord(data)
+		if err != nil {
+			t.Fatalf("ParseTXTRecord() error = %v", err)
+		}
+		if parsed != text {
+			t.Errorf("ParseTXTRecord() = %q, want %q", parsed, text)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	networks, err := dns.ParseCIDRs("10.0.0.0/8", "192.168.1.10/24", "fd00::/8")
+	if err != nil {
+		t.Fatalf("ParseCIDRs() error = %v", err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("len(networks) = %v, want %v", len(networks), 3)
+	}
+	if networks[1].String() != "192.168.1.0/24" {
+		t.Errorf("networks[1] = %v, want %v", networks[1], "192.168.1.0/24")
+	}
+
+	if _, err := dns.ParseCIDRs("not-a-cidr"); err == nil {
+		t.Errorf("ParseCIDRs() expected error for invalid CIDR")
+	}
+}
+
+func TestNewQueryID(t *testing.T) {
+	first := dns.NewQueryID()
+	for i := 0; i < 32; i++ {
+		if dns.NewQueryID() != first {
+			return
+		}
+	}
+	t.Errorf("NewQueryID() returned %d 33 times in a row, want random IDs", first)
+}
+
+func TestQueryLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	queryLog, err := dns.NewQueryLog(path, 200)
+	if err != nil {
+		t.Fatalf("NewQueryLog() error = %v", err)
+	}
+	defer queryLog.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := queryLog.Write(dns.QueryLogEntry{Name: "www.example.com", Type: "A"}); err != nil {
+				t.Errorf("Write() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range []string{path, path + ".1"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s is %d bytes, want at most the 200 byte limit", name, info.Size())
+		}
+	}
+}
+
+func TestQueryLogRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	queryLog, err := dns.NewQueryLog(path, 100)
+	if err != nil {
+		t.Fatalf("NewQueryLog() error = %v", err)
+	}
+	defer queryLog.Close()
+
+	// A non-empty directory where the rotated file should go makes the
+	// rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := dns.QueryLogEntry{Name: "www.example.com", Type: "A"}
+	if err := queryLog.Write(entry); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := queryLog.Write(entry); err == nil {
+		t.Errorf("Write() with a failed rotation returned no error")
+	}
+
+	// Once the way is clear the log rotates and carries on
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := queryLog.Write(entry); err != nil {
+		t.Fatalf("Write() after the failed rotation error = %v", err)
+	}
+
+	var lines int
+	for _, name := range []string{path, path + ".1"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 3 {
+		t.Errorf("Logs hold %d entries, want all 3", lines)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	blocklist := dns.NewBlocklist("Tracker.Example.com", "*.ads.example.com", "*foo.com")
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"tracker.example.com", true},
+		{"TRACKER.example.com.", true},
+		{"banner.ads.example.com", true},
+		{"a.b.ads.example.com", true},
+		{"ads.example.com", false}, // Wildcards only match subdomains
+		{"badads.example.com", false},
+		{"www.example.com", false},
+		{"barfoo.com", false}, // Without the dot "*foo.com" is no wildcard
+		{"bar.foo.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := blocklist.Blocked(tt.name); got != tt.want {
+			t.Errorf("Blocked(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	query, err := dns.NewQuery(0x1234, "www.example.com", dns.TYPE_MX)
+	if err != nil {
+		t.Fatalf("NewQuery() error = %v", err)
+	}
+
+	msg, err := dns.ParseDNSMessage(query)
+	if err != nil {
+		t.Fatalf("ParseDNSMessage() error = %v", err)
+	}
+	if msg.Header.ID != 0x1234 || msg.Header.Flags != 0x0100 || msg.Header.QDCount != 1 {
+		t.Errorf("Header = %+v, want ID 0x1234, RD set and one question", msg.Header)
+	}
+	if len(msg.Questions) != 1 || msg.Questions[0].Name != "www.example.com" || msg.Questions[0].Type != dns.TYPE_MX {
+		t.Errorf("Questions = %+v, want www.example.com MX", msg.Questions)
+	}
+}
+
+func TestParseTypeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want uint16
+	}{
+		{"A", dns.TYPE_A},
+		{"aaaa", dns.TYPE_AAAA},
+		{"CNAME", dns.TYPE_CNAME},
+		{"Mx", dns.TYPE_MX},
+		{"TXT", dns.TYPE_TXT},
+	}
+
+	for _, tt := range tests {
+		if got, ok := dns.ParseTypeName(tt.name); !ok || got != tt.want {
+			t.Errorf("ParseTypeName(%q) = %d, %v, want %d", tt.name, got, ok, tt.want)
+		}
+	}
+	if _, ok := dns.ParseTypeName("BOGUS"); ok {
+		t.Errorf("ParseTypeName(BOGUS) ok = true, want false")
+	}
+}
+
+func TestFormatResponse(t *testing.T) {
+	mx, err := dns.MXRecord{Preference: 10, Exchange: "mail.example.com"}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := &dns.DNSMessage{
+		Header:    dns.DNSHeader{ID: 42, Flags: 0x8180},
+		Questions: []dns.DNSQuestion{{Name: "example.com", Type: dns.TYPE_MX, Class: dns.CLASS_IN}},
+		Answers: []dns.DNSResourceRecord{
+			{Name: "example.com", Type: dns.TYPE_MX, Class: dns.CLASS_IN, TTL: 300, Data: mx},
+		},
+	}
+
+	var out bytes.Buffer
+	dns.FormatResponse(&out, response, "127.0.0.1:8053", 1500*time.Microsecond)
+
+	for _, want := range []string{
+		";; status: NOERROR, id: 42",
+		";; ANSWER SECTION:",
+		"example.com.\t300\tIN\tMX\t10 mail.example.com",
+		";; Query time: 1.5ms",
+		";; SERVER: 127.0.0.1:8053",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("FormatResponse() output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if got := dns.FormatRecord(dns.DNSResourceRecord{Name: "www.example.com", Type: dns.TYPE_A, TTL: 60, Data: []byte{192, 0, 2, 1}}); got != "www.example.com.\t60\tIN\tA\t192.0.2.1" {
+		t.Errorf("FormatRecord() = %q", got)
+	}
+}
+
+func TestDNSHeaderFlagBits(t *testing.T) {
+	tests := []struct {
+		name  string
+		get   func(*dns.DNSHeader) bool
+		set   func(*dns.DNSHeader, bool)
+		flags uint16
+	}{
+		{"QR", (*dns.DNSHeader).QR, (*dns.DNSHeader).SetQR, 0x8000},
+		{"AA", (*dns.DNSHeader).AA, (*dns.DNSHeader).SetAA, 0x0400},
+		{"TC", (*dns.DNSHeader).TC, (*dns.DNSHeader).SetTC, 0x0200},
+		{"RD", (*dns.DNSHeader).RD, (*dns.DNSHeader).SetRD, 0x0100},
+		{"RA", (*dns.DNSHeader).RA, (*dns.DNSHeader).SetRA, 0x0080},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := dns.DNSHeader{Flags: 0x7803} // Opcode and RCODE bits that must survive
+			tt.set(&header, true)
+			if header.Flags != 0x7803|tt.flags || !tt.get(&header) {
+				t.Errorf("Set%s(true): Flags = %#04x, want %#04x", tt.name, header.Flags, 0x7803|tt.flags)
+			}
+			tt.set(&header, false)
+			if header.Flags != 0x7803 || tt.get(&header) {
+				t.Errorf("Set%s(false): Flags = %#04x, want %#04x", tt.name, header.Flags, 0x7803)
+			}
+		})
+	}
+}
+
+func TestDNSHeaderOpcodeAndRCODE(t *testing.T) {
+	header := dns.DNSHeader{Flags: 0x8180}
+
+	header.SetOpcode(dns.OPCODE_STATUS)
+	if header.Opcode() != dns.OPCODE_STATUS || header.Flags != 0x9180 {
+		t.Errorf("SetOpcode(STATUS): Opcode() = %d, Flags = %#04x, want %d, %#04x",
+			header.Opcode(), header.Flags, dns.OPCODE_STATUS, 0x9180)
+	}
+
+	header.SetRCODE(dns.RCODE_NXDOMAIN)
+	if header.RCODE() != dns.RCODE_NXDOMAIN || header.Flags != 0x9183 {
+		t.Errorf("SetRCODE(NXDOMAIN): RCODE() = %d, Flags = %#04x, want %d, %#04x",
+			header.RCODE(), header.Flags, dns.RCODE_NXDOMAIN, 0x9183)
+	}
+
+	// Values wider than 4 bits are masked instead of spilling into other flags
+	header.SetOpcode(0xFF)
+	header.SetRCODE(0xF0)
+	if header.Opcode() != 0x0F || header.RCODE() != 0 || header.Flags != 0xF980 {
+		t.Errorf("Flags = %#04x after oversized values, want %#04x", header.Flags, 0xF980)
 	}
 }
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"dns-server/internal/logging"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+
+	for _, tt := range tests {
+		if got, err := logging.ParseLevel(tt.input); err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := logging.ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel(verbose) error = nil, want error")
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	var out bytes.Buffer
+
+	handler, err := logging.NewHandler(&out, "warn", "json")
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	if handler.Enabled(context.Background(), slog.LevelInfo) {
+		t.Errorf("warn handler has info enabled")
+	}
+	slog.New(handler).Warn("hello")
+	if !strings.HasPrefix(out.String(), "{") {
+		t.Errorf("json handler wrote %q, want a JSON object", out.String())
+	}
+
+	out.Reset()
+	handler, err = logging.NewHandler(&out, "debug", "text")
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	slog.New(handler).Debug("hello")
+	if !strings.Contains(out.String(), "level=DEBUG") {
+		t.Errorf("text handler wrote %q, want a DEBUG text line", out.String())
+	}
+
+	if _, err := logging.NewHandler(&out, "info", "xml"); err == nil {
+		t.Errorf("NewHandler() with format xml error = nil, want error")
+	}
+	if _, err := logging.NewHandler(&out, "loud", "json"); err == nil {
+		t.Errorf("NewHandler() with level loud error = nil, want error")
+	}
+}